@@ -0,0 +1,353 @@
+// Package chord implements a DICT3 node on a Chord ring. A Node owns its
+// routing state (its successor, predecessor and finger table) and its share of
+// the DICT3 triplets, and serves them to clients and peers over JSON-RPC.
+package chord
+
+import (
+	"../smallhash"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+)
+
+// chord node type
+type ChordNode struct {
+	NodeID    ID
+	IpAddress string
+	Port      int
+}
+
+type ChordArray struct {
+	Self        ChordNode
+	Successor   ChordNode
+	Predecessor ChordNode
+
+	//Milliseconds left before the node that called gives up; see Query
+	Timeout int64 `json:"timeout,omitempty"`
+}
+
+// A question about the ring, such as the successor of an ID, asked of another
+// node with the time left before the node asking gives up on it; the peer stops
+// working on it, and on the calls it makes to answer, after that. On the wire
+// it is a ChordNode with a "timeout" added.
+type Query struct {
+	ChordNode
+
+	//Milliseconds left, as in Operation; zero when the asker has no deadline
+	Timeout int64 `json:"timeout,omitempty"`
+}
+
+// One step of an iterative lookup, as answered by NEXT_HOP: Node is the
+// successor of the ID asked about if Done is set, and otherwise the next node to
+// ask. Successors, the successor list of the node that answered, are where the
+// lookup goes on if Node does not answer.
+type NextHop struct {
+	Node       ChordNode
+	Done       bool
+	Successors []ChordNode
+}
+
+// Reply to JOIN: the successor of the joining node, or, when Collision is set,
+// the node that already holds the joining node's ID
+type JoinReply struct {
+	Successor ChordNode
+	Collision bool
+}
+
+// CollisionError is returned by Join when another node already has our NodeID
+type CollisionError struct {
+	NodeID ID
+	Holder ChordNode
+}
+
+func (e *CollisionError) Error() string {
+	return fmt.Sprintf("node ID %s is already taken by %s:%d; pin a different nodeID or set a salt in the config file", e.NodeID, e.Holder.IpAddress, e.Holder.Port)
+}
+
+// TimeoutError is returned by a call to a peer that did not answer in time: within
+// CallTimeout, or before the request the call was made for ran out of time
+type TimeoutError struct {
+	Peer   ChordNode
+	Method string
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("%s on %s:%d timed out", e.Method, e.Peer.IpAddress, e.Peer.Port)
+}
+
+// Ring size in bits (m) when the config file does not set one
+const BITSIZE = 8
+
+// Config file Params for Node
+type ConfigParamsType struct {
+	ServerID                   string
+	Protocol                   string
+	IpAddress                  string
+	Port                       int
+	PersistentStorageContainer PersistentStorageContainerType
+	Methods                    []string
+
+	//Ring maintenance periods in milliseconds; zero means the default
+	StabilizeInterval        int
+	FixFingersInterval       int
+	CheckPredecessorInterval int
+
+	//How often access times touched by reads are written to the database, in
+	//milliseconds; zero means the default
+	FlushInterval int
+
+	//How long a node waits for another to answer one call, and how long a
+	//client's request may take as a whole, in milliseconds; zero means the default
+	CallTimeout    int
+	RequestTimeout int
+
+	//How the owner of an ID is found: "recursive" (the default), each node
+	//forwarding the query to the next, or "iterative", the node asking each hop
+	//itself. A client operation may ask for either; see Operation
+	Routing string
+
+	//Number of successors each node keeps track of (r); zero means the default
+	SuccessorListSize int
+
+	//Number of successors each triplet is copied to (k); zero means the default
+	Replicas int
+
+	//Ring size in bits (m), between 8 and MAXBITS; zero means BITSIZE
+	RingBits int
+
+	//Hash function placing nodes and triplets on the ring: "sha1" (the default) or "sha256"
+	Hash string
+
+	//Decimal NodeID to use instead of the hash of the address; empty means hash it
+	NodeID string
+
+	//Hashed along with the address, to move a node whose ID collides with another's
+	Salt string
+}
+
+// Where a node keeps its data. Type picks the Store: "json" (the default) keeps
+// everything in memory and in the File as JSON, "kv" keeps only the keys in
+// memory and the rest in the File, and "memory" keeps nothing on disk
+type PersistentStorageContainerType struct {
+	Type string
+	File string
+}
+
+// Params of a request, or results of a response
+type DICT3Item []interface{}
+
+// A List of Triplets (data stored in the node)
+type Dict3 []Triplet
+
+// Triplets copied from their owner to one of its successors. Method is "sync"
+// to replace everything held for Owner, "insertOrUpdate" or "delete" for a single write.
+// A sync also lists Targets, the successors Owner copies its triplets to.
+type Replica struct {
+	Owner   ChordNode
+	Method  string
+	Data    Dict3
+	Targets []ChordNode
+}
+
+// What PURGE_DATA removes: triplets not read for Age or longer. With DryRun
+// nothing is removed, only reported.
+type PurgeArgs struct {
+	Age    time.Duration
+	DryRun bool
+}
+
+// What one node purged, or would purge on a dry run. Denied lists the read-only
+// triplets that were old enough but kept.
+type PurgeReport struct {
+	Node     ChordNode  `json:"node"`
+	Removed  int        `json:"removed"`
+	Triplets [][]string `json:"triplets"`
+	Denied   [][]string `json:"denied"`
+}
+
+// Request from client
+type Operation struct {
+	Method string      `json:"method"`
+	Params DICT3Item   `json:"params"`
+	Id     interface{} `json:"id"`
+
+	//"recursive" or "iterative": how the owner of the triplet is found, in place
+	//of the routing in the node's config file
+	Routing string `json:"routing,omitempty"`
+
+	//Milliseconds left of the request when a node passes it on to another, which
+	//gives up on it after that; zero for a request straight from a client
+	Timeout int64 `json:"timeout,omitempty"`
+
+	//How many times nodes have passed the request on to another; see Router
+	Hops int `json:"hops,omitempty"`
+
+	//the request's deadline while this node works on it; see requestContext.
+	//Never sent to peers.
+	ctx context.Context
+
+	//set by the server codec for a JSON-RPC 2.0 request: sends part of the
+	//result to the client ahead of the response. Never sent to peers.
+	stream func(node ChordNode, result DICT3Item) error
+}
+
+// Response from server; Error is set instead of Result when the request failed
+type Get struct {
+	Result DICT3Item   `json:"result"`
+	Id     interface{} `json:"id"`
+	Error  *Error      `json:"error"`
+}
+
+// JSON-RPC 2.0 error codes. The first four are reserved by the specification;
+// the rest are DICT3's own.
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	UnknownMethod  = -32601
+	BadParams      = -32602
+	InternalError  = -32603
+
+	NotFound         = -32001
+	PermissionDenied = -32002
+	OwnerUnreachable = -32003
+	Timeout          = -32004
+)
+
+// A JSON-RPC 2.0 error object
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+// purgeArgs reads the [age, dryRun] params of PURGE. The age is a duration
+// such as "36h" or "90m", or a number of hours as older clients send it; dryRun
+// may be left out. A bad request is reported in g and ok is false.
+func (d *Operation) purgeArgs(g *Get) (args PurgeArgs, ok bool) {
+	if len(d.Params) < 1 || len(d.Params) > 2 {
+		g.fail(BadParams, "params are [age, dryRun]", nil)
+		return args, false
+	}
+	var e error
+	switch age := d.Params[0].(type) {
+	case string:
+		if hours, e2 := strconv.Atoi(age); e2 == nil {
+			args.Age = time.Duration(hours) * time.Hour
+		} else {
+			args.Age, e = time.ParseDuration(age)
+		}
+	case float64:
+		args.Age = time.Duration(age * float64(time.Hour))
+	default:
+		e = errors.New("age must be a duration or a number of hours")
+	}
+	if e == nil && args.Age < 0 {
+		e = errors.New("age must not be negative")
+	}
+	if e != nil {
+		g.fail(BadParams, e.Error(), d.Params[0])
+		return args, false
+	}
+	if len(d.Params) == 2 {
+		if args.DryRun, ok = d.Params[1].(bool); !ok {
+			g.fail(BadParams, "dryRun must be true or false", d.Params[1])
+			return args, false
+		}
+	}
+	return args, true
+}
+
+// streamParam reads the optional stream param of a partial LOOKUP, [key,
+// relation, stream]. Streaming needs a JSON-RPC 2.0 client; other clients get
+// the whole result at once. A bad request is reported in g and ok is false.
+func (d *Operation) streamParam(g *Get) (stream bool, ok bool) {
+	if len(d.Params) < 3 {
+		return false, true
+	}
+	if stream, ok = d.Params[2].(bool); !ok {
+		g.fail(BadParams, "stream must be true or false", d.Params[2])
+		return false, false
+	}
+	return stream && d.stream != nil, true
+}
+
+// fail makes g report an error instead of a result
+func (g *Get) fail(code int, message string, data interface{}) {
+	g.Result = nil
+	g.Error = &Error{Code: code, Message: message, Data: data}
+}
+
+// checkParams makes g report BadParams unless d carries count params, the first
+// of them a string
+func checkParams(d *Operation, g *Get, count int) bool {
+	if len(d.Params) < count {
+		g.fail(BadParams, fmt.Sprintf("%s takes %d params", d.Method, count), nil)
+		return false
+	}
+	if _, ok := d.Params[0].(string); !ok {
+		g.fail(BadParams, fmt.Sprintf("%s takes a string", d.Method), nil)
+		return false
+	}
+	return true
+}
+
+// checkRouting makes g report BadParams unless d asks for a routing there is
+func checkRouting(d *Operation, g *Get) bool {
+	switch d.Routing {
+	case "", "recursive", "iterative":
+		return true
+	}
+	g.fail(BadParams, "routing must be \"recursive\" or \"iterative\"", d.Routing)
+	return false
+}
+
+/*
+	This function receives as input the [IpAddress and Port] of a node and returns the hash
+	Input: hash function, Ip and Port number, ring size in bits
+
+Output: hash
+*/
+func IPHash(h smallhash.Hasher, ipAndPort string, bits int) ID {
+	return NewID(h.Hash(ipAndPort, bits))
+}
+
+/*
+This function receives as input the [key] or [rel] compound key
+
+	Input: hash function, [key] or [rel] compound key, ring size in bits
+	Output: the key hash, bits-bits/2 wide, or the relation hash, bits/2 wide
+*/
+func KRHash_Key(h smallhash.Hasher, key string, bits int) ID {
+	return NewID(h.Hash(key, bits-bits/2))
+}
+func KRHash_Rel(h smallhash.Hasher, rel string, bits int) ID {
+	return NewID(h.Hash(rel, bits/2))
+}
+
+// KRHash is the ring position of the compound key [key, rel] on a ring of the
+// given size: the key hash in the upper half of the bits and the relation hash
+// in the lower half
+func KRHash(h smallhash.Hasher, key string, rel string, bits int) ID {
+	return krID(KRHash_Key(h, key, bits), KRHash_Rel(h, rel, bits), bits)
+}
+
+// KRHash_KeyRange returns the first and last ring positions of the compound keys
+// [key, rel] for any rel. They share the upper bits, so the range is contiguous.
+func KRHash_KeyRange(h smallhash.Hasher, key string, bits int) (ID, ID) {
+	k := KRHash_Key(h, key, bits)
+	lastRel := PowerOfTwo(bits/2).Sub(IDFromUint64(1), bits/2) //all ones
+	return krID(k, ID{}, bits), krID(k, lastRel, bits)
+}
+
+// krID puts a key hash and a relation hash together into a ring position
+func krID(k ID, r ID, bits int) ID {
+	key := new(big.Int).Lsh(k.Big(), uint(bits/2))
+	return NewID(key.Or(key, r.Big()))
+}
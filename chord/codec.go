@@ -0,0 +1,207 @@
+package chord
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/rpc"
+	"strings"
+	"sync"
+)
+
+/*
+serverCodec reads requests and writes responses for the JRPC service. It speaks
+JSON-RPC 1.0 the way net/rpc/jsonrpc does, which is what peers and client.go use,
+and JSON-RPC 2.0 for any request that carries "jsonrpc": "2.0".
+
+In 2.0 the method may be given bare ("lookup" for JRPC.LOOKUP), the params of a
+client operation are the triplet itself, and failures come back as error objects
+with a code: see the constants next to Error. A handler may also stream parts of
+its result ahead of the response, as "result" notifications carrying the request
+id; see Operation. A client operation may carry "routing" next to its params, to
+pick how the owner is found for that request.
+*/
+type serverCodec struct {
+	dec *json.Decoder
+	enc *json.Encoder
+	c   io.Closer
+
+	req serverRequest
+
+	//serializes writes: responses and the notifications streamed by handlers
+	writeMu sync.Mutex
+
+	mutex   sync.Mutex
+	seq     uint64
+	pending map[uint64]*pendingRequest
+}
+
+// newServerCodec returns a codec for the JRPC service on conn
+func newServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return &serverCodec{
+		dec:     json.NewDecoder(conn),
+		enc:     json.NewEncoder(conn),
+		c:       conn,
+		pending: make(map[uint64]*pendingRequest),
+	}
+}
+
+type serverRequest struct {
+	Version string           `json:"jsonrpc"`
+	Method  string           `json:"method"`
+	Params  *json.RawMessage `json:"params"`
+	Id      *json.RawMessage `json:"id"`
+	Routing string           `json:"routing"`
+}
+
+// what the response to a request needs to know about it
+type pendingRequest struct {
+	id        *json.RawMessage
+	v2        bool
+	badParams bool
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	c.req = serverRequest{}
+	if e := c.dec.Decode(&c.req); e != nil {
+		switch e.(type) {
+		case *json.SyntaxError, *json.UnmarshalTypeError:
+			//net/rpc drops the connection; tell the client why first
+			c.write(map[string]interface{}{"jsonrpc": "2.0", "id": nil, "error": &Error{Code: ParseError, Message: e.Error()}})
+		}
+		return e
+	}
+
+	r.ServiceMethod = c.req.Method
+	v2 := c.req.Version == "2.0"
+	if v2 && r.ServiceMethod != "" && !strings.Contains(r.ServiceMethod, ".") {
+		r.ServiceMethod = "JRPC." + strings.ToUpper(r.ServiceMethod)
+	}
+
+	c.mutex.Lock()
+	c.seq++
+	c.pending[c.seq] = &pendingRequest{id: c.req.Id, v2: v2}
+	r.Seq = c.seq
+	c.mutex.Unlock()
+	return nil
+}
+
+func (c *serverCodec) ReadRequestBody(x interface{}) error {
+	if x == nil {
+		return nil
+	}
+	e := c.readParams(x)
+	if e != nil {
+		c.mutex.Lock()
+		c.pending[c.seq].badParams = true
+		c.mutex.Unlock()
+	}
+	return e
+}
+
+func (c *serverCodec) readParams(x interface{}) error {
+	if c.req.Params == nil {
+		return errors.New("missing params")
+	}
+	if c.req.Version != "2.0" {
+		//1.0 sends the argument as the only element of an array
+		var params [1]interface{}
+		params[0] = x
+		return json.Unmarshal(*c.req.Params, &params)
+	}
+
+	op, ok := x.(*Operation)
+	if !ok {
+		return json.Unmarshal(*c.req.Params, x)
+	}
+	op.Method = c.req.Method
+	op.Routing = c.req.Routing
+	if c.req.Id != nil {
+		if e := json.Unmarshal(*c.req.Id, &op.Id); e != nil {
+			return e
+		}
+		id := c.req.Id
+		op.stream = func(node ChordNode, result DICT3Item) error {
+			return c.notify("result", map[string]interface{}{"id": id, "node": node, "result": result})
+		}
+	}
+	return json.Unmarshal(*c.req.Params, &op.Params)
+}
+
+var null = json.RawMessage([]byte("null"))
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, x interface{}) error {
+	c.mutex.Lock()
+	req, ok := c.pending[r.Seq]
+	if !ok {
+		c.mutex.Unlock()
+		return errors.New("invalid sequence number in response")
+	}
+	delete(c.pending, r.Seq)
+	c.mutex.Unlock()
+
+	id := req.id
+	if id == nil {
+		if req.v2 {
+			//a 2.0 request without an id is a notification, which gets no response
+			return nil
+		}
+		id = &null
+	}
+
+	if !req.v2 {
+		resp := struct {
+			Id     *json.RawMessage `json:"id"`
+			Result interface{}      `json:"result"`
+			Error  interface{}      `json:"error"`
+		}{Id: id}
+		if r.Error == "" {
+			resp.Result = x
+		} else {
+			resp.Error = r.Error
+		}
+		return c.write(resp)
+	}
+
+	resp := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	if r.Error != "" {
+		resp["error"] = rpcError(r.Error, req.badParams)
+	} else if g, ok := x.(*Get); ok && g.Error != nil {
+		resp["error"] = g.Error
+	} else if ok {
+		resp["result"] = g.Result
+	} else {
+		resp["result"] = x
+	}
+	return c.write(resp)
+}
+
+// notify sends a 2.0 notification, a message the client does not answer
+func (c *serverCodec) notify(method string, params interface{}) error {
+	return c.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (c *serverCodec) write(x interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.enc.Encode(x)
+}
+
+func (c *serverCodec) Close() error {
+	return c.c.Close()
+}
+
+// rpcError turns an error reported by net/rpc or a handler into an error object
+func rpcError(message string, badParams bool) *Error {
+	switch {
+	case badParams:
+		return &Error{Code: BadParams, Message: message}
+	case strings.HasPrefix(message, "rpc: can't find"):
+		return &Error{Code: UnknownMethod, Message: message}
+	case strings.Contains(message, "ill-formed"):
+		return &Error{Code: InvalidRequest, Message: message}
+	case strings.HasSuffix(message, " timed out"):
+		return &Error{Code: Timeout, Message: message}
+	}
+	return &Error{Code: InternalError, Message: message}
+}
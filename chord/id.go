@@ -0,0 +1,123 @@
+package chord
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+)
+
+// Width of an identifier in bytes; a ring has at most MAXBITS = 160 bit IDs
+const IDBYTES = 20
+const MAXBITS = 8 * IDBYTES
+
+// ID is a position on the identifier circle: an unsigned, big-endian number
+// below 2^m, where m is the ring size in bits. IDs are compared with == and Cmp,
+// and travel over JSON-RPC as decimal strings.
+type ID [IDBYTES]byte
+
+// NewID returns the ID of x, which must not be negative, keeping its low 160 bits
+func NewID(x *big.Int) ID {
+	var id ID
+	b := x.Bytes()
+	if len(b) > IDBYTES {
+		b = b[len(b)-IDBYTES:]
+	}
+	copy(id[IDBYTES-len(b):], b)
+	return id
+}
+
+// IDFromUint64 returns the ID of x
+func IDFromUint64(x uint64) ID {
+	return NewID(new(big.Int).SetUint64(x))
+}
+
+// ParseID reads an ID written in decimal
+func ParseID(s string) (ID, error) {
+	x, ok := new(big.Int).SetString(s, 10)
+	if !ok || x.Sign() < 0 || x.BitLen() > MAXBITS {
+		return ID{}, errors.New("invalid ID: " + s)
+	}
+	return NewID(x), nil
+}
+
+// PowerOfTwo returns 2^i, for i below 160
+func PowerOfTwo(i int) ID {
+	return NewID(new(big.Int).Lsh(big.NewInt(1), uint(i)))
+}
+
+func (id ID) Big() *big.Int {
+	return new(big.Int).SetBytes(id[:])
+}
+
+// Cmp returns -1, 0 or +1 as id is less than, equal to or greater than x
+func (id ID) Cmp(x ID) int {
+	return bytes.Compare(id[:], x[:])
+}
+
+// Add returns id + x modulo 2^bits
+func (id ID) Add(x ID, bits int) ID {
+	return NewID(new(big.Int).Add(id.Big(), x.Big())).Mask(bits)
+}
+
+// Sub returns id - x modulo 2^bits
+func (id ID) Sub(x ID, bits int) ID {
+	d := new(big.Int).Sub(id.Big(), x.Big())
+	return NewID(d.Mod(d, new(big.Int).Lsh(big.NewInt(1), uint(bits))))
+}
+
+// In reports whether id is in the interval (lo, hi] of the ring, going clockwise
+// from lo; when lo == hi the interval is the whole ring
+func (id ID) In(lo ID, hi ID) bool {
+	switch lo.Cmp(hi) {
+	case -1:
+		return lo.Cmp(id) < 0 && id.Cmp(hi) <= 0
+	case 1:
+		return lo.Cmp(id) < 0 || id.Cmp(hi) <= 0
+	}
+	return true
+}
+
+// Between reports whether id is in the open interval (lo, hi) of the ring; when
+// lo == hi that is every ID but lo
+func (id ID) Between(lo ID, hi ID) bool {
+	return id != hi && id.In(lo, hi)
+}
+
+// Mask keeps the low bits of id, that is id modulo 2^bits
+func (id ID) Mask(bits int) ID {
+	for i := 0; i < IDBYTES; i++ {
+		low := 8 * (IDBYTES - 1 - i) // the lowest bit held by id[i]
+		if low >= bits {
+			id[i] = 0
+		} else if low+8 > bits {
+			id[i] &= byte(1<<uint(bits-low)) - 1
+		}
+	}
+	return id
+}
+
+func (id ID) String() string {
+	return id.Big().String()
+}
+
+func (id ID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+func (id *ID) UnmarshalText(text []byte) error {
+	x, e := ParseID(string(text))
+	if e != nil {
+		return e
+	}
+	*id = x
+	return nil
+}
+
+// UnmarshalJSON accepts a bare number as well as a string, so peers and clients
+// that still send IDs as JSON numbers are understood
+func (id *ID) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	return id.UnmarshalText(bytes.Trim(data, `"`))
+}
@@ -0,0 +1,160 @@
+package chord
+
+import (
+	"math/big"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+// distance returns how far clockwise to is from from, on a ring of 2^bits IDs
+func distance(from ID, to ID, bits int) *big.Int {
+	d := new(big.Int).Sub(to.Big(), from.Big())
+	return d.Mod(d, new(big.Int).Lsh(big.NewInt(1), uint(bits)))
+}
+
+// randomID returns an ID below 2^bits; the ends of the ring, and a few IDs,
+// come up often, so that intervals meet and wrap
+func randomID(r *rand.Rand, bits int) ID {
+	switch r.Intn(8) {
+	case 0:
+		return ID{}
+	case 1:
+		return ID{}.Sub(IDFromUint64(1), bits)
+	case 2:
+		return IDFromUint64(uint64(r.Intn(4)))
+	}
+	return NewID(new(big.Int).Rand(r, new(big.Int).Lsh(big.NewInt(1), uint(bits))))
+}
+
+// In and Between against the distances clockwise from lo, on rings of every size
+func TestIntervals(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for _, bits := range []int{2, 3, 8, 16, 64, 160} {
+		for i := 0; i < 20000; i++ {
+			lo, hi, id := randomID(r, bits), randomID(r, bits), randomID(r, bits)
+			if r.Intn(4) == 0 {
+				hi = lo
+			}
+			if r.Intn(4) == 0 {
+				id = []ID{lo, hi}[r.Intn(2)]
+			}
+			d, span := distance(lo, id, bits), distance(lo, hi, bits)
+
+			in := lo == hi || d.Sign() > 0 && d.Cmp(span) <= 0
+			if id.In(lo, hi) != in {
+				t.Fatalf("%d bits: %s.In(%s, %s) = %v", bits, id, lo, hi, !in)
+			}
+			between := id != lo && (lo == hi || d.Cmp(span) < 0)
+			if id.Between(lo, hi) != between {
+				t.Fatalf("%d bits: %s.Between(%s, %s) = %v", bits, id, lo, hi, !between)
+			}
+		}
+	}
+}
+
+func TestIntervalWrap(t *testing.T) {
+	top := ID{}.Sub(IDFromUint64(1), MAXBITS)
+	lo, hi := top.Sub(IDFromUint64(1), MAXBITS), IDFromUint64(1)
+	for _, c := range []struct {
+		id          ID
+		in, between bool
+	}{
+		{lo, false, false},
+		{top, true, true},
+		{ID{}, true, true},
+		{hi, true, false},
+		{IDFromUint64(2), false, false},
+		{PowerOfTwo(100), false, false},
+	} {
+		if c.id.In(lo, hi) != c.in || c.id.Between(lo, hi) != c.between {
+			t.Errorf("%s in (%s, %s]: %v, in (%s, %s): %v", c.id, lo, hi, c.id.In(lo, hi), lo, hi, c.id.Between(lo, hi))
+		}
+	}
+	//the whole ring, but for lo itself when open
+	if !lo.In(lo, lo) || lo.Between(lo, lo) || !hi.Between(lo, lo) {
+		t.Error("lo == hi is not the whole ring")
+	}
+	if top.Add(IDFromUint64(1), MAXBITS) != (ID{}) || (ID{}).Sub(IDFromUint64(1), MAXBITS) != top {
+		t.Error("Add and Sub do not wrap around the ring")
+	}
+}
+
+// CLOSEST_PRECEDING_NODE on random rings, with some fingers suspect, against the
+// live finger nearest before the request
+func TestClosestPrecedingNode(t *testing.T) {
+	const bits = 16
+	r := rand.New(rand.NewSource(2))
+	for round := 0; round < 200; round++ {
+		//a ring of distinct IDs, in order
+		seen := make(map[ID]bool)
+		var ring []ChordNode
+		for size := 1 + r.Intn(40); len(ring) < size; {
+			id := randomID(r, bits)
+			if !seen[id] {
+				seen[id] = true
+				ring = append(ring, ChordNode{NodeID: id, IpAddress: "127.0.0.1", Port: 1000 + len(ring)})
+			}
+		}
+		sort.Slice(ring, func(i, j int) bool { return ring[i].NodeID.Cmp(ring[j].NodeID) < 0 })
+		successor := func(id ID) ChordNode {
+			for _, s := range ring {
+				if s.NodeID.Cmp(id) >= 0 {
+					return s
+				}
+			}
+			return ring[0]
+		}
+
+		self := ring[r.Intn(len(ring))]
+		n := NewNode(ConfigParamsType{IpAddress: self.IpAddress, Port: self.Port, RingBits: bits, NodeID: self.NodeID.String()})
+		finger := make([]ChordNode, bits)
+		for i := range finger {
+			finger[i] = successor(self.NodeID.Add(PowerOfTwo(i), bits))
+		}
+		n.setFingers(finger)
+		n.suspects = make(map[ChordNode]time.Time)
+		for _, s := range ring {
+			if r.Intn(4) == 0 {
+				n.suspects[s] = time.Now()
+			}
+		}
+
+		for i := 0; i < 50; i++ {
+			request := ChordNode{NodeID: randomID(r, bits)}
+			want := self
+			for _, f := range finger {
+				if _, bad := n.suspects[f]; bad || !f.NodeID.Between(self.NodeID, request.NodeID) {
+					continue
+				}
+				if distance(self.NodeID, f.NodeID, bits).Cmp(distance(self.NodeID, want.NodeID, bits)) > 0 {
+					want = f
+				}
+			}
+			var got ChordNode
+			n.CLOSEST_PRECEDING_NODE(&request, &got)
+			if got != want {
+				t.Fatalf("ring of %d, self %s, request %s: %s, not %s", len(ring), self.NodeID, request.NodeID, got.NodeID, want.NodeID)
+			}
+		}
+		n.peers.close()
+	}
+}
+
+// IDs read back from their decimal form, and nothing off the ring does
+func TestIDText(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for i := 0; i < 1000; i++ {
+		id := randomID(r, MAXBITS)
+		back, e := ParseID(id.String())
+		if e != nil || back != id || id.String() != id.Big().String() {
+			t.Fatalf("%s reads back as %s, %v", id, back, e)
+		}
+	}
+	for _, bad := range []string{"", "-1", "x", new(big.Int).Lsh(big.NewInt(1), MAXBITS).String(), "1e3"} {
+		if _, e := ParseID(bad); e == nil {
+			t.Errorf("ParseID(%q) does not fail", bad)
+		}
+	}
+}
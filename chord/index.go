@@ -0,0 +1,153 @@
+package chord
+
+// a compound key [key, rel]
+type keyRel struct {
+	key string
+	rel string
+}
+
+/*
+index finds the triplets of the node by key and by relation, without scanning its
+store; the store finds them by compound key itself. It is kept up to date by put
+and remove, one triplet at a time, and built by KR_Hash_All when the store is
+opened. Guarded by dataMu like the store.
+*/
+type index struct {
+	rels map[string]map[string]bool //relations of each key
+	keys map[string]map[string]bool //keys of each relation
+}
+
+func (x *index) reset() {
+	x.rels = make(map[string]map[string]bool)
+	x.keys = make(map[string]map[string]bool)
+}
+
+func (x *index) add(t Triplet) {
+	if x.rels[t.Key] == nil {
+		x.rels[t.Key] = make(map[string]bool)
+	}
+	x.rels[t.Key][t.Relation] = true
+	if x.keys[t.Relation] == nil {
+		x.keys[t.Relation] = make(map[string]bool)
+	}
+	x.keys[t.Relation][t.Key] = true
+}
+
+func (x *index) drop(key string, rel string) {
+	delete(x.rels[key], rel)
+	if len(x.rels[key]) == 0 {
+		delete(x.rels, key)
+	}
+	delete(x.keys[rel], key)
+	if len(x.keys[rel]) == 0 {
+		delete(x.keys, rel)
+	}
+}
+
+// get returns the triplet [key, rel], and whether the node has it. The caller
+// must hold n.dataMu
+func (n *Node) get(key string, rel string) (Triplet, bool, error) {
+	t, ok, e := n.store.Get(key, rel)
+	if at, touched := n.touched[keyRel{key, rel}]; ok && touched {
+		t.Value.Accessed = at
+	}
+	return t, ok, e
+}
+
+// matches returns the triplets whose key, and relation, are the ones given; an
+// empty key or relation matches any. The caller must hold n.dataMu
+func (n *Node) matches(key string, rel string) (Dict3, error) {
+	var found Dict3
+	add := func(key string, rel string) error {
+		t, ok, e := n.get(key, rel)
+		if ok {
+			found = append(found, t)
+		}
+		return e
+	}
+	switch {
+	case key != "" && rel != "":
+		return found, add(key, rel)
+	case key != "":
+		for r := range n.index.rels[key] {
+			if e := add(key, r); e != nil {
+				return nil, e
+			}
+		}
+	case rel != "":
+		for k := range n.index.keys[rel] {
+			if e := add(k, rel); e != nil {
+				return nil, e
+			}
+		}
+	default:
+		return n.scan(ID{}, ID{})
+	}
+	return found, nil
+}
+
+// scan returns the triplets whose ring position is in (lo, hi], all of them
+// when lo == hi. The caller must hold n.dataMu
+func (n *Node) scan(lo ID, hi ID) (Dict3, error) {
+	var found Dict3
+	e := n.store.Scan(lo, hi, func(t Triplet) bool {
+		if at, ok := n.touched[keyRel{t.Key, t.Relation}]; ok {
+			t.Value.Accessed = at
+		}
+		found = append(found, t)
+		return true
+	})
+	return found, e
+}
+
+// put stores t, in place of the triplet with the same key and relation if there
+// is one. The caller must hold n.dataMu
+func (n *Node) put(t Triplet) error {
+	if e := n.store.Put(t); e != nil {
+		return e
+	}
+	delete(n.touched, keyRel{t.Key, t.Relation})
+	n.index.add(t)
+	return nil
+}
+
+// remove deletes the triplet [key, rel]. The caller must hold n.dataMu
+func (n *Node) remove(key string, rel string) error {
+	if e := n.store.Delete(key, rel); e != nil {
+		return e
+	}
+	delete(n.touched, keyRel{key, rel})
+	n.index.drop(key, rel)
+	return nil
+}
+
+// putAll stores triplets handed over in bulk. The caller must hold n.dataMu
+func (n *Node) putAll(dict3 Dict3) error {
+	for _, t := range dict3 {
+		if e := n.put(t); e != nil {
+			return e
+		}
+	}
+	n.rewrite()
+	return nil
+}
+
+// takeRange removes the triplets whose ring position is in (lo, hi], all of them
+// when lo == hi, and returns them to be handed over. The caller must hold n.dataMu
+func (n *Node) takeRange(lo ID, hi ID) (Dict3, error) {
+	taken, e := n.scan(lo, hi)
+	if e != nil {
+		return nil, e
+	}
+	for i, t := range taken {
+		if e := n.remove(t.Key, t.Relation); e != nil {
+			//put back what was removed; nothing is handed over
+			for _, t := range taken[:i] {
+				n.put(t)
+			}
+			return nil, e
+		}
+	}
+	n.rewrite()
+	return taken, nil
+}
@@ -0,0 +1,121 @@
+package chord
+
+import (
+	"strconv"
+	"testing"
+)
+
+// indexedNode returns a node, not started, with an in-memory store holding keys
+// keys of rels relations each, and the same triplets as a slice
+func indexedNode(b *testing.B, keys int, rels int) (*Node, Dict3) {
+	n := NewNode(ConfigParamsType{IpAddress: "127.0.0.1", Port: 1, RingBits: 32})
+	var e error
+	if n.store, e = openStore(PersistentStorageContainerType{Type: "memory"}, n.krHash); e != nil {
+		b.Fatal(e)
+	}
+	var dict3 Dict3
+	for k := 0; k < keys; k++ {
+		for r := 0; r < rels; r++ {
+			t := Triplet{Key: "key" + strconv.Itoa(k), Relation: "rel" + strconv.Itoa(r), Value: valuetype{Content: "x", Permission: "RW"}}
+			if e := n.put(t); e != nil {
+				b.Fatal(e)
+			}
+			dict3 = append(dict3, t)
+		}
+	}
+	return n, dict3
+}
+
+// linearMatches is how a node found triplets before the index: by a scan of all
+func linearMatches(dict3 Dict3, key string, rel string) Dict3 {
+	var found Dict3
+	for _, t := range dict3 {
+		if (key == "" || t.Key == key) && (rel == "" || t.Relation == rel) {
+			found = append(found, t)
+		}
+	}
+	return found
+}
+
+var sizes = []struct {
+	name       string
+	keys, rels int
+}{{"1k", 100, 10}, {"10k", 1000, 10}, {"100k", 10000, 10}}
+
+func BenchmarkLookup(b *testing.B) {
+	for _, s := range sizes {
+		n, dict3 := indexedNode(b, s.keys, s.rels)
+		key, rel := "key"+strconv.Itoa(s.keys/2), "rel5"
+		b.Run("index/"+s.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				n.get(key, rel)
+			}
+		})
+		b.Run("scan/"+s.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				findItem(dict3, Triplet{Key: key, Relation: rel})
+			}
+		})
+	}
+}
+
+func BenchmarkMatchKey(b *testing.B) {
+	for _, s := range sizes {
+		n, dict3 := indexedNode(b, s.keys, s.rels)
+		key := "key" + strconv.Itoa(s.keys/2)
+		b.Run("index/"+s.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				n.matches(key, "")
+			}
+		})
+		b.Run("scan/"+s.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				linearMatches(dict3, key, "")
+			}
+		})
+	}
+}
+
+func BenchmarkMatchRelation(b *testing.B) {
+	//one relation that only a few keys have
+	for _, s := range sizes {
+		n, dict3 := indexedNode(b, s.keys, s.rels)
+		for k := 0; k < 10; k++ {
+			t := Triplet{Key: "key" + strconv.Itoa(k), Relation: "rare", Value: valuetype{Permission: "RW"}}
+			n.put(t)
+			dict3 = append(dict3, t)
+		}
+		b.Run("index/"+s.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				n.matches("", "rare")
+			}
+		})
+		b.Run("scan/"+s.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				linearMatches(dict3, "", "rare")
+			}
+		})
+	}
+}
+
+func BenchmarkUpdate(b *testing.B) {
+	for _, s := range sizes {
+		n, dict3 := indexedNode(b, s.keys, s.rels)
+		t := Triplet{Key: "key" + strconv.Itoa(s.keys/2), Relation: "rel5", Value: valuetype{Content: "y", Permission: "RW"}}
+		b.Run("index/"+s.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				n.put(t)
+			}
+		})
+		//the slice was searched, and every ring position hashed again after a write
+		hashes := make([]ID, len(dict3))
+		b.Run("scan/"+s.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				dict3[findItem(dict3, t)] = t
+				for j := range dict3 {
+					hashes[j] = n.krHash(dict3[j].Key, dict3[j].Relation)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,1165 @@
+package chord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// get predecessor of a given node
+func (r *JRPC) GET_PREDECESSOR(request *ChordNode, response *ChordNode) error {
+	n := r.node
+	_, _, pred := n.neighbours()
+
+	response.IpAddress = pred.IpAddress
+	response.Port = pred.Port
+	response.NodeID = pred.NodeID
+	return nil
+}
+
+// get successor of a given node
+func (r *JRPC) GET_SUCCESSOR(request *ChordNode, response *ChordNode) error {
+	n := r.node
+	_, succ, _ := n.neighbours()
+	response.IpAddress = succ.IpAddress
+	response.Port = succ.Port
+	response.NodeID = succ.NodeID
+	return nil
+}
+
+// get the successor list of a given node, nearest first
+func (r *JRPC) GET_SUCCESSOR_LIST(request *ChordNode, response *[]ChordNode) error {
+	n := r.node
+	*response = n.successorList()
+	return nil
+}
+
+// Parameter 1: ChordNode object, whose successor you want to find
+// Parameter 2: returns a ChordNode object,
+func (r *JRPC) FIND_SUCCESSOR(request *Query, response *ChordNode) error {
+	ctx, cancel := r.node.queryContext(request.Timeout)
+	defer cancel()
+	return r.node.findSuccessor(ctx, &request.ChordNode, response)
+}
+
+// findSuccessor answers FIND_SUCCESSOR, forwarding the query around the ring
+// until ctx is done
+func (n *Node) findSuccessor(ctx context.Context, request *ChordNode, response *ChordNode) error {
+	if s, ok := n.localSuccessor(request); ok {
+		*response = s
+		return nil
+	}
+	self := n.self
+
+	//forward to the closest preceding finger; a finger that does not answer is
+	//dropped from the table by n.call, so the next try picks another one
+	e := n.router.try(ctx, n.bits+1, "JRPC.FIND_SUCCESSOR", &Query{ChordNode: *request}, response, func() (ChordNode, error) {
+		var Nprime ChordNode
+		n.CLOSEST_PRECEDING_NODE(request, &Nprime)
+		if Nprime == self {
+			//never forward a query back to ourselves
+			_, Nprime, _ = n.neighbours()
+		}
+		if Nprime == self {
+			return ChordNode{}, nil
+		}
+		return Nprime, nil
+	})
+	if e == errNoLiveNode {
+		//everyone we knew of has failed; we are the best answer we have
+		*response = self
+		return nil
+	}
+	return e
+}
+
+// localSuccessor returns the successor of request when it is this node or its
+// successor; ok is false when the query has to go on to another node
+func (n *Node) localSuccessor(request *ChordNode) (s ChordNode, ok bool) {
+	self, succ, pred := n.neighbours()
+
+	//an unknown successor is ourselves, and then stands for no interval rather
+	//than the whole ring
+	if holds(request.NodeID, self, succ, pred) {
+		return self, true
+	} else if succ != self && request.NodeID.In(self.NodeID, succ.NodeID) {
+		return succ, true
+	}
+	return s, false
+}
+
+// One step of an iterative lookup for request; see route.go
+func (r *JRPC) NEXT_HOP(request *Query, response *NextHop) error {
+	*response = r.node.nextHop(request.ChordNode)
+	return nil
+}
+
+// request wants to join the ring: find its successor, unless its ID is already taken
+func (r *JRPC) JOIN(q *Query, response *JoinReply) error {
+	ctx, cancel := r.node.queryContext(q.Timeout)
+	defer cancel()
+	request := &q.ChordNode
+	if e := r.node.findSuccessor(ctx, request, &response.Successor); e != nil {
+		return e
+	}
+	//a node rejoining from the same address may take its old place back; the ring
+	//may still route to its last run, whose successor is then its successor too
+	s := response.Successor
+	if s == *request {
+		after := ChordNode{NodeID: request.NodeID.Add(IDFromUint64(1), r.node.bits)}
+		if e := r.node.findSuccessor(ctx, &after, &response.Successor); e != nil {
+			return e
+		}
+		s = response.Successor
+	}
+	response.Collision = s.NodeID == request.NodeID && (s.IpAddress != request.IpAddress || s.Port != request.Port)
+	if response.Collision {
+		fmt.Printf("Rejected %s:%d: NodeID %s is taken by %s:%d \n", request.IpAddress, request.Port, request.NodeID, s.IpAddress, s.Port)
+	}
+	return nil
+}
+
+// request thinks it might be our predecessor; the previous predecessor is returned
+func (r *JRPC) NOTIFY(request *ChordNode, response *ChordNode) error {
+	n := r.node
+
+	n.mu.Lock()
+	self, pred := n.self, n.predecessor
+	*response = pred
+	changed := pred == self || request.NodeID.Between(pred.NodeID, self.NodeID)
+	if changed {
+		n.predecessor = *request
+	}
+	n.mu.Unlock()
+
+	if changed {
+		n.promoteReplicas(ChordNode{})
+	}
+	return nil
+}
+
+// liveness check used by CHECK_PREDECESSOR
+func (r *JRPC) PING(request *ChordNode, response *ChordNode) error {
+	*response = r.node.self
+	return nil
+}
+
+func (r *JRPC) NOTIFY_PREDECESSOR(request *ChordNode, response *ChordNode) error {
+	n := r.node
+
+	n.mu.Lock()
+	if n.predecessor == n.self {
+
+		response.IpAddress = n.predecessor.IpAddress
+		response.Port = n.predecessor.Port
+		response.NodeID = n.predecessor.NodeID
+		n.predecessor.IpAddress = request.IpAddress
+		n.predecessor.Port = request.Port
+		n.predecessor.NodeID = request.NodeID
+	} else {
+
+		/*if n.predecessor.NodeID > n.self.NodeID {
+
+			if (request.NodeID > n.predecessor.NodeID && request.NodeID > n.self.NodeID) || (request.NodeID < n.predecessor.NodeID && request.NodeID < n.self.NodeID) {
+				response.IpAddress = n.predecessor.IpAddress
+				response.Port = n.predecessor.Port
+				response.NodeID = n.predecessor.NodeID
+				n.predecessor.IpAddress = request.IpAddress
+				n.predecessor.Port = request.Port
+				n.predecessor.NodeID = request.NodeID
+
+			}
+
+		} else {
+
+			if request.NodeID > n.predecessor.NodeID && request.NodeID < n.self.NodeID {*/
+
+		response.IpAddress = n.predecessor.IpAddress
+		response.Port = n.predecessor.Port
+		response.NodeID = n.predecessor.NodeID
+		n.predecessor.IpAddress = request.IpAddress
+		n.predecessor.Port = request.Port
+		n.predecessor.NodeID = request.NodeID
+	}
+	/*}
+
+		}
+
+	}*/
+	pred, succ := n.predecessor, n.successor
+	n.mu.Unlock()
+	fmt.Printf("Predecessor: %s:%d | NodeID: %s \n", pred.IpAddress, pred.Port, pred.NodeID)
+	fmt.Printf("Successor:    %s:%d | NodeID: %s \n", succ.IpAddress, succ.Port, succ.NodeID)
+	return nil
+
+}
+
+func (r *JRPC) NOTIFY_SUCCESSOR(request *ChordNode, response *ChordNode) error {
+	n := r.node
+
+	n.mu.Lock()
+	if n.successor == n.self {
+		n.successor.IpAddress = request.IpAddress
+		n.successor.Port = request.Port
+		n.successor.NodeID = request.NodeID
+	} else {
+
+		/*if n.self.NodeID > n.successor.NodeID {
+
+			if (request.NodeID > n.self.NodeID && request.NodeID > n.successor.NodeID) || (request.NodeID < n.self.NodeID && request.NodeID < n.successor.NodeID) {
+
+				n.successor.IpAddress = request.IpAddress
+				n.successor.Port = request.Port
+				n.successor.NodeID = request.NodeID
+
+			}
+
+		} else {
+
+			if (request.NodeID > n.self.NodeID && request.NodeID < n.successor.NodeID {*/
+
+		n.successor.IpAddress = request.IpAddress
+		n.successor.Port = request.Port
+		n.successor.NodeID = request.NodeID
+	}
+	/*}
+
+		}
+
+	}*/
+	n.spliceSuccessor()
+	pred, succ := n.predecessor, n.successor
+	n.mu.Unlock()
+	fmt.Printf("Predecessor: %s:%d | NodeID: %s \n", pred.IpAddress, pred.Port, pred.NodeID)
+	fmt.Printf("Successor:    %s:%d | NodeID: %s \n", succ.IpAddress, succ.Port, succ.NodeID)
+	return nil
+
+}
+
+// data transfer from successor
+func (r *JRPC) DATA_TRANSFER_FROM_SUCCESSOR(request *ChordNode, response *Dict3) error {
+	n := r.node
+	lo, hi := n.router.Range()
+	if lo == hi {
+		//we know of no predecessor, and keep the whole ring
+		return nil
+	}
+
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+
+	//we keep what we own; the rest is the joining node's now
+	transfer, e := n.takeRange(hi, lo)
+	if e != nil {
+		return e
+	}
+	*response = append(*response, transfer...)
+	n.replicasStale()
+
+	return nil
+
+}
+
+// data transfer from predecessor
+func (r *JRPC) DATA_TRANSFER_FROM_PREDECESSOR(request *Dict3, response *ChordNode) error {
+	n := r.node
+
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+
+	if e := n.putAll(*request); e != nil {
+		return e
+	}
+	n.replicasStale()
+
+	return nil
+
+}
+
+// data transfer from predecessor
+func (r *JRPC) DATA_TRANSFER_FROM_PREDECESSOR_REVERSE(request *ChordNode, response *Dict3) error {
+	n := r.node
+
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+
+	transfer, e := n.takeRange(ID{}, ID{})
+	if e != nil {
+		return e
+	}
+	*response = append(*response, transfer...)
+	n.replicasStale()
+
+	return nil
+
+}
+
+// store a copy of triplets owned by one of our predecessors
+func (r *JRPC) REPLICATE(request *Replica, response *ChordNode) error {
+	n := r.node
+
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+
+	switch request.Method {
+	case "sync":
+		n.replicas[request.Owner] = request.Data
+		n.prune(request.Owner, request.Targets)
+	case "insertOrUpdate":
+		held := n.replicas[request.Owner]
+		for _, item := range request.Data {
+			if i := findItem(held, item); i >= 0 {
+				held[i] = item
+			} else {
+				held = append(held, item)
+			}
+		}
+		n.replicas[request.Owner] = held
+	case "delete":
+		held := n.replicas[request.Owner]
+		for _, item := range request.Data {
+			if i := findItem(held, item); i >= 0 {
+				held = append(held[:i], held[i+1:]...)
+			}
+		}
+		n.replicas[request.Owner] = held
+	default:
+		return errors.New("replicate: unknown method " + request.Method)
+	}
+	return nil
+}
+
+// Look up data in our own triplets and in the replicas we hold, whoever owns it
+func (r *JRPC) LOOKUP_REPLICA(d *Operation, o *Dict3) error {
+	n := r.node
+	var g Get
+	key, rel, ok := d.keyRel(&g)
+	if !ok {
+		return g.Error
+	}
+
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+
+	t, ok, e := n.get(key, rel)
+	if e != nil {
+		return e
+	}
+	if ok {
+		n.touch(&t)
+		*o = append(*o, t)
+		return nil
+	}
+	//the last modified copy, should more than one owner have sent one
+	var latest *Triplet
+	for _, held := range n.replicas {
+		for i := range held {
+			if held[i].Key == key && held[i].Relation == rel && (latest == nil || held[i].Value.Modified.After(latest.Value.Modified)) {
+				latest = &held[i]
+			}
+		}
+	}
+	if latest != nil {
+		*o = append(*o, *latest)
+	}
+	return nil
+}
+
+// the replicas we hold for request
+func (r *JRPC) REPLICAS_OF(request *ChordNode, response *Dict3) error {
+	n := r.node
+
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+
+	*response = append(*response, n.replicas[*request]...)
+	return nil
+}
+
+// Fix finger tables by nodes and their successors
+func (r *JRPC) FIX_FINGER(g *ChordArray, o *ChordNode) error {
+	n := r.node
+	self, succ, _ := n.neighbours()
+	ctx, cancel := n.queryContext(g.Timeout)
+	defer cancel()
+
+	//fmt.Printf("%d\n",g.Successor.NodeID)
+	var p ChordNode
+	var s ChordNode
+	var t ChordNode
+	var count int
+	count = 0
+
+	finger := n.fingers()
+
+	for i := 0; i < n.bits; i++ { //one row per bit of the ring: there are (at most) m rows/entries in the finger table
+
+		p = self
+		s = succ
+
+		t.NodeID = p.NodeID.Add(PowerOfTwo(i), n.bits) //calculate id for each row
+		//fmt.Printf("%d: %d,%d,%d\n",i, t.NodeID,p.NodeID, s.NodeID)
+		//fmt.Printf("%d\n",t.NodeID)
+
+		if i == 0 {
+			finger[i] = s // the first row of finger table is its successor
+			continue
+		} else {
+
+			for {
+				//this part is used to find the successor
+				if t.NodeID == p.NodeID {
+					finger[i] = p
+					break
+				} else if t.NodeID.In(p.NodeID, s.NodeID) {
+					finger[i] = s
+					break
+				}
+				p = s
+				//fmt.Printf("%d: %d,%d,%d,%d,%d\n",i, t.NodeID,p.NodeID, s.NodeID,Self.NodeID,g.Self.NodeID)
+				if (s.NodeID != self.NodeID) && (s.NodeID != g.Self.NodeID) {
+					if e := n.call(ctx, s, "JRPC.GET_SUCCESSOR", t, &s); e != nil {
+						return e
+					}
+				} else if (s.NodeID != self.NodeID) && (s.NodeID == g.Self.NodeID) {
+					s.NodeID = g.Successor.NodeID
+					s.IpAddress = g.Successor.IpAddress
+					s.Port = g.Successor.Port
+					//fmt.Printf("%d: %d,%d,%d,%d,%d,%d\n",i, t.NodeID,p.NodeID, s.NodeID,Self.NodeID,g[0].NodeID,g[1].NodeID)
+				} else {
+					s = succ
+				}
+				//fmt.Printf("%d: %d,%d,%d,%d,%d\n",i, t.NodeID,p.NodeID, s.NodeID,Self.NodeID,g[0].NodeID)
+			}
+		}
+		count = count + 1
+	}
+	n.setFingers(finger)
+	//PRINT_FINGERTABLE()
+	return nil
+}
+
+// Look up function: allow complete/uncomplete keys. If uncomplete keys are input, every triplet whose key, or relation, is exactly the one given is returned to client; a JSON-RPC 2.0 client may ask for them to be streamed with [key, relation, true]
+func (r *JRPC) LOOKUP(d *Operation, o *Get) error {
+	n := r.node
+	self := n.self
+
+	var hashnum []ID
+	hashnum = hashnum[:0]
+
+	var tmpo Dict3
+	tmpo = tmpo[:0]
+
+	var loop int
+
+	o.Result = nil
+	o.Id = d.Id
+	o.Error = nil
+
+	key, rel, ok := d.keyRel(o)
+	if !ok || !checkRouting(d, o) {
+		return nil
+	}
+	ctx, cancel := n.requestContext(d)
+	defer cancel()
+
+	if key != "" && rel != "" {
+		loop = 1
+		//fmt.Printf("%d\n", loop)
+		//hashnum = int[0:loop]
+		hashnum = append(hashnum, n.krHash(key, rel))
+		//fmt.Printf("%d, %d", loop, hashnum[0])
+	} else {
+		//a partial key hashes to no single position: ask each node that may hold a
+		//match for its exact matches
+		stream, ok := d.streamParam(o)
+		if !ok {
+			return nil
+		}
+		found := 0
+		e := n.router.Match(ctx, d, func(S ChordNode, matches DICT3Item) error {
+			found += len(matches)
+			if stream && len(matches) > 0 {
+				return d.stream(S, matches)
+			}
+			o.Result = append(o.Result, matches...)
+			return nil
+		})
+		if e == nil && found == 0 {
+			o.fail(NotFound, "no triplet matches", []string{key, rel})
+		} else if e == nil && stream {
+			//the matches went out ahead of the response, which ends the stream
+			o.Result = DICT3Item{}
+		}
+		return e
+	}
+
+	for k := 0; k < loop; k++ {
+
+		//fmt.Printf("%d, %d", loop, hashnum[k])
+		owner, e := n.router.Read(ctx, hashnum[k], d, &tmpo)
+		if e = report(o, owner, e); e != nil || o.Error != nil {
+			return e
+		}
+		if owner != self {
+			fmt.Printf("%s: %s:%d\n", hashnum[k], owner.IpAddress, owner.Port)
+		}
+
+		//if tmpo!=nil{
+		for i := 0; i < len(tmpo); i++ {
+			o.Result = append(o.Result, tmpo[i])
+		}
+		//}
+	}
+
+	if len(o.Result) == 0 {
+		o.fail(NotFound, "no such triplet", []string{key, rel})
+	}
+	tmpo = tmpo[:0]
+	return nil
+}
+
+// Look up data
+func (r *JRPC) LOOKUP_DATA(d *Operation, o *Dict3) error {
+	n := r.node
+
+	var g Get
+	key, rel, ok := d.keyRel(&g)
+	if !ok {
+		return g.Error
+	}
+	ctx, cancel := n.requestContext(d)
+	defer cancel()
+
+	_, e := n.router.Read(ctx, n.krHash(key, rel), d, o)
+	return e
+}
+
+// Look up the triplets on this node whose key, or relation, is exactly the one given; an empty key or relation matches any
+func (r *JRPC) LOOKUP_MATCH_DATA(d *Operation, g *Get) error {
+	n := r.node
+	key, rel, ok := d.keyRel(g)
+	if !ok {
+		return nil
+	}
+
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+
+	found, e := n.matches(key, rel)
+	if e != nil {
+		return e
+	}
+	for i := range found {
+		n.touch(&found[i])
+		g.Result = append(g.Result, found[i])
+	}
+	g.Error = nil
+	return nil
+}
+
+// Trace the route to the owner of [key, relation]: the result is the owner, then
+// the nodes asked on the way, in order, starting with this one. The route is
+// always found iteratively; a recursive lookup does not see past its first hop.
+func (r *JRPC) ROUTE(d *Operation, g *Get) error {
+	n := r.node
+	g.Id = d.Id
+	key, rel, ok := d.keyRel(g)
+	if !ok {
+		return nil
+	}
+	if key == "" || rel == "" {
+		g.fail(BadParams, "route takes a whole [key, relation]", nil)
+		return nil
+	}
+
+	ctx, cancel := n.requestContext(d)
+	defer cancel()
+	owner, route, e := n.router.Trace(ctx, n.krHash(key, rel))
+	if e != nil {
+		g.fail(unreachableCode(e), e.Error(), route)
+		return nil
+	}
+	g.Result = DICT3Item{owner, route}
+	return nil
+}
+
+// Insert a data
+func (r *JRPC) INSERT(d *Operation, o *Get) error {
+	n := r.node
+
+	o.Id = d.Id
+	t, ok := d.triplet(o)
+	if !ok || !checkRouting(d, o) {
+		return nil
+	}
+
+	ctx, cancel := n.requestContext(d)
+	defer cancel()
+	return n.router.Apply(ctx, n.krHash(t.Key, t.Relation), "JRPC.INSERT_DATA", d, o, r.INSERT_DATA)
+}
+
+func (r *JRPC) INSERT_DATA(d *Operation, o *Get) error {
+	n := r.node
+	o.Id = d.Id
+	t, ok := d.triplet(o)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := n.requestContext(d)
+	defer cancel()
+	if moved, e := n.router.Redirect(ctx, n.krHash(t.Key, t.Relation), "JRPC.INSERT_DATA", d, o); moved {
+		return e
+	}
+	var flag bool
+	flag = false
+
+	n.dataMu.Lock()
+	//var index int
+
+	_, flag, e := n.get(t.Key, t.Relation)
+	if e != nil {
+		n.dataMu.Unlock()
+		return e
+	}
+
+	if flag != false {
+		x := []interface{}{false}
+		o.Result = x
+		o.Id = d.Id
+		o.Error = nil
+
+	} else {
+		x := []interface{}{true}
+		o.Result = x
+		o.Id = d.Id
+		o.Error = nil
+		t.stamp(nil)
+		if e := n.put(t); e != nil {
+			n.dataMu.Unlock()
+			return e
+		}
+	}
+
+	n.dataMu.Unlock()
+
+	if flag == false {
+		n.replicate(ctx, "insertOrUpdate", t)
+	}
+
+	return nil
+}
+
+func (r *JRPC) INSERTORUPDATE(d *Operation, g *Get) error {
+	n := r.node
+
+	g.Id = d.Id
+	t, ok := d.triplet(g)
+	if !ok || !checkRouting(d, g) {
+		return nil
+	}
+
+	ctx, cancel := n.requestContext(d)
+	defer cancel()
+	return n.router.Apply(ctx, n.krHash(t.Key, t.Relation), "JRPC.INSERTORUPDATE_DATA", d, g, r.INSERTORUPDATE_DATA)
+}
+
+func (r *JRPC) INSERTORUPDATE_DATA(d *Operation, g *Get) error {
+	n := r.node
+	g.Id = d.Id
+	t, ok := d.triplet(g)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := n.requestContext(d)
+	defer cancel()
+	if moved, e := n.router.Redirect(ctx, n.krHash(t.Key, t.Relation), "JRPC.INSERTORUPDATE_DATA", d, g); moved {
+		return e
+	}
+	var flag bool
+	flag = false
+
+	n.dataMu.Lock()
+
+	old, flag, e := n.get(t.Key, t.Relation)
+	if e != nil {
+		n.dataMu.Unlock()
+		return e
+	}
+
+	//var x []interface{}
+	//tmpVal = dict3[index][2].(map[string]interface{})
+	//if tmpVal["permission"].(string) == "RW" {
+	if flag != false {
+		if !checkWrite(old, g) {
+			n.dataMu.Unlock()
+			return nil
+		}
+		t.stamp(&old)
+
+	} else {
+
+		t.stamp(nil)
+	}
+	if e := n.put(t); e != nil {
+		n.dataMu.Unlock()
+		return e
+	}
+	//}
+	//fmt.Printf("Results: %v\n", dict3)
+	n.dataMu.Unlock()
+
+	n.replicate(ctx, "insertOrUpdate", t)
+
+	return nil
+}
+func (r *JRPC) DELETE(d *Operation, g *Get) error {
+	n := r.node
+
+	g.Id = d.Id
+	key, rel, ok := d.keyRel(g)
+	if !ok || !checkRouting(d, g) {
+		return nil
+	}
+
+	ctx, cancel := n.requestContext(d)
+	defer cancel()
+	return n.router.Apply(ctx, n.krHash(key, rel), "JRPC.DELETE_DATA", d, g, r.DELETE_DATA)
+}
+
+func (r *JRPC) DELETE_DATA(d *Operation, g *Get) error {
+	n := r.node
+	g.Id = d.Id
+	key, rel, ok := d.keyRel(g)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := n.requestContext(d)
+	defer cancel()
+	if moved, e := n.router.Redirect(ctx, n.krHash(key, rel), "JRPC.DELETE_DATA", d, g); moved {
+		return e
+	}
+	var flag bool
+	flag = false
+
+	n.dataMu.Lock()
+
+	deleted, flag, e := n.get(key, rel)
+	if e != nil {
+		n.dataMu.Unlock()
+		return e
+	}
+
+	//var x []interface{}
+
+	if flag != false {
+		if !checkWrite(deleted, g) {
+			n.dataMu.Unlock()
+			return nil
+		}
+		if e := n.remove(key, rel); e != nil {
+			n.dataMu.Unlock()
+			return e
+		}
+
+	} else {
+		g.fail(NotFound, "no such triplet", []string{key, rel})
+	}
+	//fmt.Printf("Results: %v\n", dict3)
+
+	n.dataMu.Unlock()
+
+	if flag != false {
+		n.replicate(ctx, "delete", deleted)
+	}
+
+	return nil
+}
+
+// Purge the triplets not read within the given age from every node of the ring;
+// the result has one PurgeReport per node
+func (r *JRPC) PURGE(d *Operation, g *Get) error {
+	n := r.node
+
+	g.Result = nil
+	g.Id = d.Id
+	g.Error = nil
+
+	args, ok := d.purgeArgs(g)
+	if !ok {
+		return nil
+	}
+
+	var report PurgeReport
+	if e := r.PURGE_DATA(&args, &report); e != nil {
+		return e
+	}
+	g.Result = append(g.Result, report)
+
+	//walk the rest of the ring, skipping any node that does not answer
+	ctx, cancel := n.requestContext(d)
+	defer cancel()
+	return n.router.Walk(ctx, func(S ChordNode) error {
+		var tmpr PurgeReport
+		if e := n.call(ctx, S, "JRPC.PURGE_DATA", args, &tmpr); e != nil {
+			return e
+		}
+		g.Result = append(g.Result, tmpr)
+		return nil
+	})
+}
+
+func (r *JRPC) PURGE_DATA(d *PurgeArgs, report *PurgeReport) error {
+	n := r.node
+
+	report.Node, _, _ = n.neighbours()
+	report.Triplets = [][]string{}
+	report.Denied = [][]string{}
+
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+
+	dict3, e := n.scan(ID{}, ID{})
+	if e != nil {
+		return e
+	}
+	for i := 0; i < len(dict3); i++ {
+		//Find the time duration since the access time until now
+		duration := time.Since(dict3[i].Value.Accessed)
+
+		if duration < d.Age {
+			continue
+		}
+		if !dict3[i].writable() {
+			report.Denied = append(report.Denied, []string{dict3[i].Key, dict3[i].Relation})
+		} else {
+			report.Triplets = append(report.Triplets, []string{dict3[i].Key, dict3[i].Relation})
+		}
+	}
+	report.Removed = len(report.Triplets)
+	if d.DryRun || report.Removed == 0 {
+		return nil
+	}
+	for _, kr := range report.Triplets {
+		if e := n.remove(kr[0], kr[1]); e != nil {
+			n.rewrite()
+			return e
+		}
+	}
+	n.rewrite()
+
+	//the replicas are replaced in full on the next stabilize
+	n.replicasStale()
+
+	return nil
+}
+
+func (r *JRPC) LISTKEYS(d *Operation, g *Get) error {
+	n := r.node
+
+	g.Result = nil
+	n.dataMu.Lock()
+	dict3, e := n.scan(ID{}, ID{})
+	n.dataMu.Unlock()
+	if e != nil {
+		return e
+	}
+	for i := 0; i < len(dict3); i++ {
+
+		g.Result = append(g.Result, dict3[i].Key)
+
+	}
+	//fmt.Printf("Results: %v\n", g.Result)
+	g.Id = d.Id
+	g.Error = nil
+
+	//walk the rest of the ring, skipping any node that does not answer
+	ctx, cancel := n.requestContext(d)
+	defer cancel()
+	return n.router.Walk(ctx, func(S ChordNode) error {
+		var tmpg *Get
+		if e := n.call(ctx, S, "JRPC.LISTKEYS_DATA", d, &tmpg); e != nil {
+			return e
+		}
+		for i := 0; i < len(tmpg.Result); i++ {
+
+			g.Result = append(g.Result, tmpg.Result[i])
+
+		}
+		return nil
+	})
+}
+
+func (r *JRPC) LISTKEYS_DATA(d *Operation, g *Get) error {
+	n := r.node
+
+	g.Result = nil
+	n.dataMu.Lock()
+	dict3, e := n.scan(ID{}, ID{})
+	n.dataMu.Unlock()
+	if e != nil {
+		return e
+	}
+	for i := 0; i < len(dict3); i++ {
+
+		g.Result = append(g.Result, dict3[i].Key)
+
+	}
+	//fmt.Printf("Results: %v\n", g.Result)
+	g.Id = d.Id
+	g.Error = nil
+
+	return nil
+}
+
+func (r *JRPC) LISTIDS(d *Operation, g *Get) error {
+	n := r.node
+
+	var p []string
+
+	//g.Result = nil
+	//p = nil
+
+	var ttt []interface{}
+	//ttt = nil
+
+	n.dataMu.Lock()
+	dict3, e := n.scan(ID{}, ID{})
+	n.dataMu.Unlock()
+	if e != nil {
+		return e
+	}
+	for i := 0; i < len(dict3); i++ {
+		p = append(p, dict3[i].Key)
+		p = append(p, dict3[i].Relation)
+
+		//g.Result = append(g.Result, p)
+		ttt = append(ttt, p)
+
+		//fmt.Printf("Results: %v\n", g.Result[i])
+
+		//p = p[:0]
+	}
+	g.Result = append(g.Result, p)
+	fmt.Printf("Results: %s\n", ttt)
+	fmt.Printf("Results: %s\n", g.Result)
+	g.Id = d.Id
+	g.Error = nil
+
+	//walk the rest of the ring, skipping any node that does not answer
+	ctx, cancel := n.requestContext(d)
+	defer cancel()
+	return n.router.Walk(ctx, func(S ChordNode) error {
+		var tmpg *Get
+		if e := n.call(ctx, S, "JRPC.LISTIDS_DATA", d, &tmpg); e != nil {
+			return e
+		}
+		for i := 0; i < len(tmpg.Result); i++ {
+
+			g.Result = append(g.Result, tmpg.Result[i])
+
+		}
+		return nil
+	})
+}
+
+func (r *JRPC) LISTIDS_DATA(d *Operation, g *Get) error {
+	n := r.node
+
+	var p []string
+
+	g.Result = nil
+	n.dataMu.Lock()
+	dict3, e := n.scan(ID{}, ID{})
+	n.dataMu.Unlock()
+	if e != nil {
+		return e
+	}
+	for i := 0; i < len(dict3); i++ {
+
+		p = append(p, dict3[i].Key)
+		p = append(p, dict3[i].Relation)
+
+		g.Result = append(g.Result, p)
+
+		p = p[:0]
+
+	}
+
+	g.Id = d.Id
+	g.Error = nil
+
+	return nil
+}
+
+// shut down one node based on the input node id of client, and data stored in that node will be transfered to its successor.
+func (r *JRPC) SHUTDOWN(d *Operation, g *Get) error {
+	n := r.node
+	self, succ, _ := n.neighbours()
+
+	var tmp ChordNode
+
+	g.Id = d.Id
+	if !checkParams(d, g, 1) || !checkRouting(d, g) {
+		return nil
+	}
+
+	var d_tmp ChordNode
+	id, e := ParseID(d.Params[0].(string))
+	if e != nil {
+		g.fail(BadParams, e.Error(), nil)
+		return nil
+	}
+	d_tmp.NodeID = id
+
+	fmt.Printf("%s\n", id)
+	ctx, cancel := n.requestContext(d)
+	defer cancel()
+
+	if d_tmp.NodeID == self.NodeID {
+
+		return n.Leave()
+	} else {
+
+		var S ChordNode
+
+		if e := n.lookup(ctx, d.Routing, &d_tmp, &S); e != nil {
+			return e
+		}
+
+		//fmt.Printf("%s:%d\n", S.IpAddress, S.Port)
+
+		if S.NodeID == d_tmp.NodeID {
+
+			fmt.Printf("node id: %s \n", S.NodeID)
+
+			var S_successor ChordNode
+			var P_predecessor ChordNode
+
+			if e := n.call(ctx, S, "JRPC.GET_SUCCESSOR", self, &S_successor); e != nil {
+				return e
+			}
+
+			fmt.Printf("s node id: %s \n", S_successor.NodeID)
+
+			if e := n.call(ctx, S, "JRPC.GET_PREDECESSOR", self, &P_predecessor); e != nil {
+				return e
+			}
+
+			fmt.Printf("p node id: %s \n", P_predecessor.NodeID)
+
+			tmp = self
+
+			if S_successor.NodeID == self.NodeID {
+
+				var tmp_dict3 Dict3
+				if e := n.call(ctx, S, "JRPC.DATA_TRANSFER_FROM_PREDECESSOR_REVERSE", tmp, &tmp_dict3); e != nil {
+					return e
+				}
+
+				n.dataMu.Lock()
+				e := n.putAll(tmp_dict3)
+				n.dataMu.Unlock()
+				if e != nil {
+					return e
+				}
+
+				fmt.Printf("equal node id: %s \n", S.NodeID)
+
+				n.setPredecessor(P_predecessor)
+				n.promoteReplicas(ChordNode{})
+
+				n.replicasStale()
+
+				//tmp = Self
+				tmp.Port = 0
+
+			}
+			if P_predecessor.NodeID == self.NodeID {
+
+				succ = S_successor
+				n.setSuccessor(succ)
+				//tmp = Self
+				tmp.Port = -1
+
+			}
+			if S_successor.NodeID == self.NodeID && P_predecessor.NodeID == self.NodeID {
+
+				tmp.Port = -2
+
+			}
+			fmt.Printf("equal node id: %s \n", S.NodeID)
+			if e := n.call(ctx, S, "JRPC.SHUTDOWN_DATA", tmp, &S); e != nil {
+				return e
+			}
+
+			if self.NodeID == succ.NodeID {
+
+				finger := make([]ChordNode, n.bits)
+				for i := 0; i < n.bits; i++ {
+					finger[i] = self
+				}
+				n.setFingers(finger)
+
+			} else if e := n.FIX_LOCAL_FINGER(ctx, self, succ); e != nil {
+				return e
+			}
+			n.PRINT_FINGERTABLE()
+			return nil
+		} else {
+
+			g.fail(NotFound, "no node has ID "+id.String(), nil)
+			return nil
+		}
+
+	}
+}
+
+func (r *JRPC) SHUTDOWN_DATA(d *ChordNode, g *ChordNode) error {
+	n := r.node
+	_, succ, pred := n.neighbours()
+	ctx, cancel := n.callContext()
+	defer cancel()
+
+	var tmp ChordNode
+
+	fmt.Printf("n node is %s \n", d.NodeID)
+
+	n.dataMu.Lock()
+	transfer, e := n.takeRange(ID{}, ID{})
+	n.dataMu.Unlock()
+	if e != nil {
+		return e
+	}
+
+	if d.Port != 0 && d.Port != -2 {
+
+		if e := n.call(ctx, succ, "JRPC.DATA_TRANSFER_FROM_PREDECESSOR", transfer, &tmp); e != nil {
+			//keep the data; we have not shut down yet
+			n.dataMu.Lock()
+			if e := n.putAll(transfer); e != nil {
+				log.Println("shutdown:", e)
+			}
+			n.dataMu.Unlock()
+			return e
+		}
+
+	}
+
+	if d.Port != 0 && d.Port != -2 {
+		if e := n.call(ctx, succ, "JRPC.NOTIFY_PREDECESSOR", pred, &tmp); e != nil {
+			return e
+		}
+	}
+
+	if d.Port != -1 && d.Port != -2 {
+		fmt.Printf("p node is %s \n", pred.NodeID)
+		fmt.Printf("s node is %s \n", succ.NodeID)
+		if e := n.call(ctx, pred, "JRPC.NOTIFY_SUCCESSOR", succ, &tmp); e != nil {
+			return e
+		}
+	}
+	return n.stop()
+}
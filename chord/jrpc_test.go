@@ -0,0 +1,257 @@
+package chord
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"../smallhash"
+)
+
+// manyRelations starts a ring whose nodes split the range of key between them,
+// and writes count relations of key, as well as triplets that only share their
+// relation or a prefix with it
+func manyRelations(t *testing.T, key string, count int) []*Node {
+	lo, _ := KRHash_KeyRange(smallhash.SHA1{}, key, 16)
+	var ids []string
+	for _, offset := range []uint64{64, 128, 192, 30000} {
+		ids = append(ids, lo.Add(IDFromUint64(offset), 16).String())
+	}
+	nodes := startRingAt(t, ids)
+
+	insert := func(key string, rel string) {
+		var g Get
+		d := &Operation{Params: DICT3Item{key, rel, map[string]interface{}{"content": key + "/" + rel, "permission": "RW"}}}
+		if e := nodes[0].jrpc.INSERT(d, &g); e != nil || g.Error != nil {
+			t.Fatal(e, g.Error)
+		}
+	}
+	for i := 0; i < count; i++ {
+		insert(key, "rel"+strconv.Itoa(i))
+		insert(key+"x", "rel"+strconv.Itoa(i))
+		insert("other"+strconv.Itoa(i), "rel3")
+	}
+	return nodes
+}
+
+// checkMatches checks that found holds each triplet matching [key, rel] once, and
+// count of them
+func checkMatches(t *testing.T, found []Triplet, key string, rel string, count int) {
+	seen := make(map[keyRel]bool)
+	for _, x := range found {
+		if key != "" && x.Key != key || rel != "" && x.Relation != rel {
+			t.Errorf("[%q, %q] matched [%q, %q]", key, rel, x.Key, x.Relation)
+		}
+		if seen[keyRel{x.Key, x.Relation}] {
+			t.Errorf("[%q, %q] is in the result twice", x.Key, x.Relation)
+		}
+		seen[keyRel{x.Key, x.Relation}] = true
+	}
+	if len(seen) != count {
+		t.Errorf("[%q, %q] found %d triplets, not %d", key, rel, len(seen), count)
+	}
+}
+
+// triplets reads a result, whose triplets from other nodes are still in JSON form
+func triplets(t *testing.T, result DICT3Item) []Triplet {
+	var found []Triplet
+	data, e := json.Marshal(result)
+	if e == nil {
+		e = json.Unmarshal(data, &found)
+	}
+	if e != nil {
+		t.Fatal(e)
+	}
+	return found
+}
+
+func TestPartialLookup(t *testing.T) {
+	nodes := manyRelations(t, "many", 30)
+
+	//the relations of one key are spread over the first three nodes
+	holders := 0
+	for _, n := range nodes {
+		n.dataMu.Lock()
+		found, _ := n.matches("many", "")
+		n.dataMu.Unlock()
+		if len(found) > 0 {
+			holders++
+		}
+	}
+	if holders < 2 {
+		t.Fatalf("the relations of one key are on %d node", holders)
+	}
+
+	for _, q := range []struct {
+		key, rel string
+		count    int
+	}{{"many", "", 30}, {"", "rel3", 32}, {"", "rel29", 2}, {"nokey", "", 0}} {
+		for _, n := range nodes {
+			var g Get
+			e := n.jrpc.LOOKUP(&Operation{Params: DICT3Item{q.key, q.rel}}, &g)
+			if e != nil {
+				t.Fatal(e)
+			}
+			if q.count == 0 {
+				if g.Error == nil || g.Error.Code != NotFound {
+					t.Errorf("[%q, %q]: %v, not NotFound", q.key, q.rel, g.Error)
+				}
+				continue
+			}
+			checkMatches(t, triplets(t, g.Result), q.key, q.rel, q.count)
+		}
+	}
+}
+
+// A JSON-RPC 2.0 client may have the matches streamed, one notification per node
+func TestPartialLookupStream(t *testing.T) {
+	nodes := manyRelations(t, "many", 30)
+
+	c, e := net.Dial("tcp", nodes[3].Address())
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer c.Close()
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, e := c.Write([]byte(`{"jsonrpc": "2.0", "method": "lookup", "params": ["many", "", true], "id": 7}` + "\n")); e != nil {
+		t.Fatal(e)
+	}
+
+	var found []Triplet
+	parts := 0
+	r := bufio.NewReader(c)
+	for {
+		line, e := r.ReadBytes('\n')
+		if e != nil {
+			t.Fatal(e)
+		}
+		var m struct {
+			Method string
+			Params struct {
+				Id     int
+				Node   ChordNode
+				Result []Triplet
+			}
+			Id     *int
+			Result []Triplet
+			Error  *Error
+		}
+		if e := json.Unmarshal(line, &m); e != nil {
+			t.Fatalf("%v: %s", e, line)
+		}
+		if m.Method == "result" {
+			if m.Params.Id != 7 {
+				t.Errorf("notification for request %d", m.Params.Id)
+			}
+			parts++
+			found = append(found, m.Params.Result...)
+			continue
+		}
+		if m.Id == nil || *m.Id != 7 || m.Error != nil || len(m.Result) != 0 {
+			t.Errorf("the stream ends with %s", line)
+		}
+		break
+	}
+	if parts < 2 {
+		t.Errorf("%d notifications, from nodes that hold matches", parts)
+	}
+	checkMatches(t, found, "many", "", 30)
+}
+
+// stored reports whether n has [key, rel] in its store, and its content
+func stored(n *Node, key string, rel string) (string, bool) {
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+	t, ok, _ := n.get(key, rel)
+	return t.Value.Content, ok
+}
+
+// A write that reaches a node which does not own its triplet, as one routed before
+// the ring changed does, is passed on to the owner rather than applied there
+func TestDataHandlersForwardToOwner(t *testing.T) {
+	nodes := startRing(t, 3)
+	o := owner(nodes, "k", "r")
+	x := nodes[0]
+	if x == o {
+		x = nodes[1]
+	}
+	write := func(content string) *Operation {
+		return &Operation{Params: DICT3Item{"k", "r", map[string]interface{}{"content": content, "permission": "RW"}}}
+	}
+
+	var g Get
+	if e := x.jrpc.INSERT_DATA(write("a"), &g); e != nil || g.Error != nil {
+		t.Fatal(e, g.Error)
+	}
+	if c, ok := stored(o, "k", "r"); !ok || c != "a" {
+		t.Errorf("insert: the owner holds %q, %v", c, ok)
+	}
+	g = Get{}
+	if e := x.jrpc.INSERTORUPDATE_DATA(write("b"), &g); e != nil || g.Error != nil {
+		t.Fatal(e, g.Error)
+	}
+	if c, ok := stored(o, "k", "r"); !ok || c != "b" {
+		t.Errorf("update: the owner holds %q, %v", c, ok)
+	}
+	if _, ok := stored(x, "k", "r"); ok {
+		t.Error("a node that does not own the triplet stored it")
+	}
+	g = Get{}
+	if e := x.jrpc.DELETE_DATA(&Operation{Params: DICT3Item{"k", "r"}}, &g); e != nil || g.Error != nil {
+		t.Fatal(e, g.Error)
+	}
+	if _, ok := stored(o, "k", "r"); ok {
+		t.Error("delete: the owner still holds the triplet")
+	}
+
+	//one passed on as often as the ring has bits is going round in circles
+	d := write("c")
+	d.Hops = x.bits
+	g = Get{}
+	if e := x.jrpc.INSERT_DATA(d, &g); e != nil || g.Error == nil {
+		t.Errorf("passed on %d times: %v %v", d.Hops, e, g.Error)
+	}
+	if _, ok := stored(o, "k", "r"); ok {
+		t.Error("a write past the hop limit was applied")
+	}
+}
+
+// A node that has lost track of its predecessor, as one that suspects it has,
+// still passes writes for other nodes' triplets on to them
+func TestUnknownPredecessorOwnsNothingElse(t *testing.T) {
+	nodes := startRing(t, 3)
+	var keys []string
+	for i := 0; len(keys) < 10; i++ {
+		key := "k" + strconv.Itoa(i)
+		if owner(nodes, key, "r") != nodes[0] {
+			keys = append(keys, key)
+		}
+	}
+	owners := make(map[string]*Node)
+	for _, key := range keys {
+		owners[key] = owner(nodes, key, "r")
+	}
+
+	nodes[0].setPredecessor(nodes[0].self)
+	for _, key := range keys {
+		if nodes[0].router.Owns(nodes[0].krHash(key, "r")) {
+			t.Errorf("%s is owned by a node with no predecessor", key)
+		}
+		var g Get
+		d := &Operation{Params: DICT3Item{key, "r", map[string]interface{}{"content": key, "permission": "RW"}}}
+		if e := nodes[0].jrpc.INSERT(d, &g); e != nil || g.Error != nil {
+			t.Fatal(e, g.Error)
+		}
+	}
+	for _, key := range keys {
+		if _, ok := stored(owners[key], key, "r"); !ok {
+			t.Errorf("%s did not reach its owner", key)
+		}
+		if _, ok := stored(nodes[0], key, "r"); ok {
+			t.Errorf("%s was stored on the node with no predecessor", key)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package chord
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+/*
+kvStore is the "kv" store, for data sets too large to keep in memory. The file
+named in the config is a log of the same records as the write-ahead log of the
+json store, one line each, appended and synced on every write. Only the keys are
+kept in memory, with where the last record of each is in the file; values are
+read from the file when needed. Records that have been replaced or deleted are
+dropped by compaction, which copies the live ones to a new file and renames it
+over the old one.
+*/
+type kvStore struct {
+	pos func(key string, rel string) ID
+
+	path string
+	file *os.File
+	size int64 //where the next record goes
+
+	keys map[keyRel]kvEntry
+	dead int //records in the file that have been replaced or deleted
+}
+
+// Where the record of one triplet is in the file, and the triplet's ring position
+type kvEntry struct {
+	at   int64
+	size int
+	id   ID
+}
+
+// dead records after which the store is compacted, if they outnumber the live ones
+const kvCompactSize = 1000
+
+// openKVStore opens the store in the file at path, creating it if it does not exist
+func openKVStore(path string, pos func(key string, rel string) ID) (*kvStore, error) {
+	s := &kvStore{pos: pos, path: path}
+	if e := s.open(); e != nil {
+		return nil, e
+	}
+	return s, nil
+}
+
+// open reads the keys in the file. A last record cut short by a crash was never
+// acknowledged, and is cut off; one whose triplet breaks the rules of a stored
+// value is logged and skipped.
+func (s *kvStore) open() error {
+	file, e := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0664)
+	if e != nil {
+		return e
+	}
+	s.file, s.size, s.keys, s.dead = file, 0, make(map[keyRel]kvEntry), 0
+
+	r := bufio.NewReader(file)
+	for {
+		line, e := r.ReadBytes('\n')
+		if e == io.EOF && len(line) > 0 {
+			log.Printf("%s: dropping a torn last record", s.path)
+			if e = file.Truncate(s.size); e == nil {
+				return nil
+			}
+		}
+		if e == io.EOF {
+			return nil
+		}
+		if e != nil {
+			file.Close()
+			s.file = nil
+			return e
+		}
+
+		var rec walRecord
+		if e = json.Unmarshal(line, &rec); e != nil && json.Valid(line) {
+			//a triplet against the rules of a stored value; compaction drops it
+			log.Printf("%s: skipping the record at offset %d, %s: %v", s.path, s.size, bytes.TrimSpace(line), e)
+			s.dead++
+			s.size += int64(len(line))
+			continue
+		}
+		if e != nil {
+			file.Close()
+			s.file = nil
+			e = errors.New("offset " + strconv.FormatInt(s.size, 10) + ": " + e.Error())
+			return &corruptError{path: s.path, files: []string{s.path}, err: e}
+		}
+		switch {
+		case rec.Op == "put" && rec.Triplet != nil:
+			s.index(rec.Triplet.Key, rec.Triplet.Relation, kvEntry{s.size, len(line), ID{}})
+		case rec.Op == "delete":
+			s.unindex(rec.Key, rec.Rel)
+		}
+		s.size += int64(len(line))
+	}
+}
+
+// index records where the last record of [key, rel] is
+func (s *kvStore) index(key string, rel string, entry kvEntry) {
+	if _, ok := s.keys[keyRel{key, rel}]; ok {
+		s.dead++
+	}
+	entry.id = s.pos(key, rel)
+	s.keys[keyRel{key, rel}] = entry
+}
+
+// unindex forgets [key, rel], whose delete record has just been read or written
+func (s *kvStore) unindex(key string, rel string) {
+	if _, ok := s.keys[keyRel{key, rel}]; ok {
+		delete(s.keys, keyRel{key, rel})
+		s.dead++
+	}
+	s.dead++
+}
+
+// read returns the record at entry
+func (s *kvStore) read(entry kvEntry) ([]byte, error) {
+	line := make([]byte, entry.size)
+	if _, e := s.file.ReadAt(line, entry.at); e != nil {
+		return nil, e
+	}
+	return line, nil
+}
+
+func (s *kvStore) Get(key string, rel string) (Triplet, bool, error) {
+	entry, ok := s.keys[keyRel{key, rel}]
+	if !ok {
+		return Triplet{}, false, nil
+	}
+	t, e := s.triplet(entry)
+	return t, e == nil, e
+}
+
+// triplet reads the triplet put by the record at entry
+func (s *kvStore) triplet(entry kvEntry) (Triplet, error) {
+	line, e := s.read(entry)
+	if e != nil {
+		return Triplet{}, e
+	}
+	var rec walRecord
+	if e = json.Unmarshal(line, &rec); e != nil || rec.Triplet == nil {
+		return Triplet{}, errors.New(s.path + ": bad record at offset " + strconv.FormatInt(entry.at, 10))
+	}
+	return *rec.Triplet, nil
+}
+
+func (s *kvStore) Put(t Triplet) error {
+	at, size, e := s.append(walRecord{Op: "put", Triplet: &t})
+	if e != nil {
+		return e
+	}
+	s.index(t.Key, t.Relation, kvEntry{at, size, ID{}})
+	return s.compactIfDead()
+}
+
+func (s *kvStore) Delete(key string, rel string) error {
+	if _, ok := s.keys[keyRel{key, rel}]; !ok {
+		return nil
+	}
+	if _, _, e := s.append(walRecord{Op: "delete", Key: key, Rel: rel}); e != nil {
+		return e
+	}
+	s.unindex(key, rel)
+	return s.compactIfDead()
+}
+
+// append writes rec at the end of the file and waits for it to reach the disk;
+// it returns where rec was written
+func (s *kvStore) append(rec walRecord) (int64, int, error) {
+	if s.file == nil {
+		return 0, 0, errors.New(s.path + ": store is closed")
+	}
+	line, e := json.Marshal(rec)
+	if e != nil {
+		return 0, 0, e
+	}
+	line = append(line, '\n')
+	if _, e = s.file.Write(line); e == nil {
+		e = s.file.Sync()
+	}
+	if e != nil {
+		//leave no part of rec behind for the next record to follow
+		s.file.Truncate(s.size)
+		return 0, 0, e
+	}
+	at := s.size
+	s.size += int64(len(line))
+	return at, len(line), nil
+}
+
+func (s *kvStore) compactIfDead() error {
+	if s.dead >= kvCompactSize && s.dead > len(s.keys) {
+		return s.compact()
+	}
+	return nil
+}
+
+func (s *kvStore) Scan(lo ID, hi ID, visit func(Triplet) bool) error {
+	for _, entry := range s.keys {
+		if !entry.id.In(lo, hi) {
+			continue
+		}
+		t, e := s.triplet(entry)
+		if e != nil {
+			return e
+		}
+		if !visit(t) {
+			break
+		}
+	}
+	return nil
+}
+
+// Snapshot compacts the file once enough of it is dead; every write is already on
+// disk, so there is nothing else to save
+func (s *kvStore) Snapshot() error {
+	return s.compactIfDead()
+}
+
+// compact copies the live records to a new file, which replaces the old one
+func (s *kvStore) compact() error {
+	tmp, e := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if e != nil {
+		return e
+	}
+	w := bufio.NewWriter(tmp)
+	for _, entry := range s.keys {
+		line, e := s.read(entry)
+		if e == nil {
+			_, e = w.Write(line)
+		}
+		if e != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return e
+		}
+	}
+	if e = w.Flush(); e == nil {
+		e = tmp.Sync()
+	}
+	if e2 := tmp.Close(); e == nil {
+		e = e2
+	}
+	if e == nil {
+		e = os.Chmod(tmp.Name(), 0664)
+	}
+	if e == nil {
+		e = os.Rename(tmp.Name(), s.path)
+	}
+	if e != nil {
+		os.Remove(tmp.Name())
+		return e
+	}
+
+	s.file.Close()
+	if e = syncDir(filepath.Dir(s.path)); e != nil {
+		//the old file is gone from the directory; keep using the new one
+		s.open()
+		return e
+	}
+	return s.open()
+}
+
+func (s *kvStore) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	//leave no dead records behind, however few
+	var e error
+	if s.dead > 0 {
+		e = s.compact()
+	}
+	if e2 := s.file.Close(); e == nil {
+		e = e2
+	}
+	s.file = nil
+	return e
+}
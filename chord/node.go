@@ -0,0 +1,798 @@
+package chord
+
+import (
+	"../smallhash"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/rpc"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A single DICT3 server: its place in the ring and the data it is responsible for.
+// Handlers run concurrently; the routing state is guarded by mu and the data by
+// dataMu, and neither lock is ever held across a call to another node.
+type Node struct {
+	mu sync.RWMutex
+
+	//The node itself; fixed once the node is created
+	self ChordNode
+
+	//Successor
+	successor ChordNode
+
+	//The first few nodes after us on the ring, nearest first; successors[0] is successor
+	successors []ChordNode
+
+	//Predecessor
+	predecessor ChordNode
+
+	//Finger table, should contain a list of chordNode objects
+	finger []ChordNode
+
+	//next finger table row to be refreshed by FIX_FINGERS
+	next int
+
+	//peers that recently failed a call, and when; see suspect
+	suspects map[ChordNode]time.Time
+
+	//the successors our data was last pushed to in full; nil forces a new push
+	replicated []ChordNode
+
+	// Configuration Parameters for the node, as read from its config file
+	NodeParams ConfigParamsType
+
+	dataMu sync.Mutex
+
+	//data, kept by a Store picked in the config file; see store.go
+	store Store
+
+	//the keys and relations of the data
+	index index
+
+	//triplets held for the nodes before us, by owner
+	replicas map[ChordNode]Dict3
+
+	//the predecessor the replicas in our range were last taken over after; see promote
+	promotedAfter ID
+
+	//access times of the triplets read since they were last written; see touch
+	touched map[keyRel]time.Time
+
+	//set by Start when the store was corrupt: Join fills it from the replicas
+	rebuild bool
+
+	//Ring size in bits (m); IDs are below 2^m and the finger table has m rows
+	bits int
+
+	//places node addresses and compound keys on the ring
+	hasher smallhash.Hasher
+
+	//connections to other nodes, kept open between calls; see pool.go
+	peers *pool
+
+	//where data operations are applied or passed on to; see router.go
+	router *Router
+
+	jrpc     *JRPC
+	server   *rpc.Server
+	listener net.Listener
+	done     chan struct{}
+
+	//cancelled when the node stops; every call to another node is made under it
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	//connections accepted from other nodes and clients
+	connMu sync.Mutex
+	conns  map[net.Conn]bool
+}
+
+// RPC service; every method is bound to the node that registered it
+type JRPC struct {
+	node *Node
+}
+
+// NewNode creates a node from its configuration. The node does not serve
+// anything until Start is called.
+func NewNode(config ConfigParamsType) *Node {
+	n := new(Node)
+	n.NodeParams = config
+
+	// bits is M, in s = successor(NodeID + 2^(i−1) ) mod 2^M, where M is the N-bit size of the ring
+	n.bits = config.RingBits
+	if n.bits == 0 {
+		n.bits = BITSIZE
+	}
+
+	n.self.IpAddress = config.IpAddress
+	n.self.Port = config.Port
+	n.hasher, _ = smallhash.New(config.Hash)
+	if n.hasher == nil {
+		n.hasher = smallhash.SHA1{} // Start reports the bad name
+	}
+	n.self.NodeID = IPHash(n.hasher, n.Address()+config.Salt, n.bits)
+	if config.NodeID != "" {
+		id, _ := ParseID(config.NodeID) // Start reports a bad ID
+		n.self.NodeID = id.Mask(n.bits)
+	}
+	n.predecessor = n.self
+	n.successor = n.self
+	n.successors = []ChordNode{n.self}
+	n.replicas = make(map[ChordNode]Dict3)
+	n.index.reset()
+	n.touched = make(map[keyRel]time.Time)
+	n.done = make(chan struct{})
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	n.jrpc = &JRPC{node: n}
+	n.router = &Router{node: n}
+	n.peers = newPool(func(ctx context.Context, addr string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, config.Protocol, addr)
+	})
+	n.conns = make(map[net.Conn]bool)
+	return n
+}
+
+// LoadConfig reads a node configuration file
+func LoadConfig(config_file string) (ConfigParamsType, error) {
+	var config ConfigParamsType
+	file, e := ioutil.ReadFile(config_file)
+	if e != nil {
+		return config, e
+	}
+	e = json.Unmarshal(file, &config)
+	return config, e
+}
+
+// Address returns the "ip:port" the node listens on
+func (n *Node) Address() string {
+	return n.self.IpAddress + ":" + strconv.Itoa(n.self.Port)
+}
+
+// neighbours returns a consistent snapshot of self, successor and predecessor
+func (n *Node) neighbours() (self ChordNode, successor ChordNode, predecessor ChordNode) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.self, n.successor, n.predecessor
+}
+
+func (n *Node) setSuccessor(s ChordNode) {
+	n.mu.Lock()
+	n.successor = s
+	n.spliceSuccessor()
+	n.mu.Unlock()
+}
+
+/*
+Bring the successor list and the first finger in line with n.successor: entries
+before it in the list are dropped, and a node not in the list is put in front.
+
+	The caller must hold n.mu
+*/
+func (n *Node) spliceSuccessor() {
+	if len(n.finger) > 0 {
+		n.finger[0] = n.successor // the first row of finger table is its successor
+	}
+	for i, s := range n.successors {
+		if s == n.successor {
+			n.successors = n.successors[i:]
+			return
+		}
+	}
+	n.successors = append([]ChordNode{n.successor}, n.successors...)
+	if len(n.successors) > n.successorListSize() {
+		n.successors = n.successors[:n.successorListSize()]
+	}
+}
+
+// successorList returns a copy of the successor list
+func (n *Node) successorList() []ChordNode {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	list := make([]ChordNode, len(n.successors))
+	copy(list, n.successors)
+	return list
+}
+
+// setSuccessorList replaces the successor list with succ followed by the list
+// succ reported; it stops short of wrapping back around to us
+func (n *Node) setSuccessorList(succ ChordNode, list []ChordNode) {
+	successors := []ChordNode{succ}
+	for _, s := range list {
+		if s == n.self || s == succ || len(successors) == n.successorListSize() {
+			break
+		}
+		successors = append(successors, s)
+	}
+
+	n.mu.Lock()
+	if n.successor == succ {
+		n.successors = successors
+	}
+	n.mu.Unlock()
+}
+
+// the number of successors to track (r), from the config file
+func (n *Node) successorListSize() int {
+	if n.NodeParams.SuccessorListSize <= 0 {
+		return 4
+	}
+	return n.NodeParams.SuccessorListSize
+}
+
+func (n *Node) setPredecessor(p ChordNode) {
+	n.mu.Lock()
+	n.predecessor = p
+	n.mu.Unlock()
+}
+
+// fingers returns a copy of the finger table
+func (n *Node) fingers() []ChordNode {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	finger := make([]ChordNode, len(n.finger))
+	copy(finger, n.finger)
+	return finger
+}
+
+func (n *Node) setFingers(finger []ChordNode) {
+	n.mu.Lock()
+	n.finger = finger
+	n.mu.Unlock()
+}
+
+// Start loads the node's DICT3 file, registers the JRPC service and begins
+// accepting connections in the background.
+func (n *Node) Start() error {
+	if n.bits < 8 || n.bits > MAXBITS {
+		return fmt.Errorf("ringBits must be between 8 and %d, not %d", MAXBITS, n.bits)
+	}
+	if _, e := smallhash.New(n.NodeParams.Hash); e != nil {
+		return e
+	}
+	switch n.NodeParams.Routing {
+	case "", "recursive", "iterative":
+	default:
+		return fmt.Errorf("routing must be \"recursive\" or \"iterative\", not %q", n.NodeParams.Routing)
+	}
+	if n.NodeParams.NodeID != "" {
+		id, e := ParseID(n.NodeParams.NodeID)
+		if e != nil {
+			return e
+		}
+		if id != id.Mask(n.bits) {
+			return fmt.Errorf("nodeID %s does not fit in a %d bit ring", id, n.bits)
+		}
+	}
+
+	n.dataMu.Lock()
+	var e error
+	n.store, e = openStore(n.NodeParams.PersistentStorageContainer, n.krHash)
+	if c, ok := e.(*corruptError); ok {
+		//start empty; Join rebuilds our part of the ring from the replicas
+		log.Println(e)
+		if e = c.quarantine(); e == nil {
+			n.store, e = openStore(n.NodeParams.PersistentStorageContainer, n.krHash)
+			n.rebuild = true
+		}
+	}
+	if e == nil {
+		e = n.KR_Hash_All()
+	}
+	n.dataMu.Unlock()
+	if e != nil {
+		return e
+	}
+	fmt.Println("Opened DICT3 File successfully")
+
+	//jsonrpc service object; jrpc is actually the Dict3 Service that provides methods (or remote procedures) such as INSERT, LOOKUP etc
+	n.server = rpc.NewServer()
+	if e = n.server.RegisterName("JRPC", n.jrpc); e != nil {
+		return e
+	}
+
+	n.listener, e = net.Listen(n.NodeParams.Protocol, n.Address())
+	if e != nil {
+		return e
+	}
+	go n.serve()
+	return nil
+}
+
+func (n *Node) serve() {
+	for {
+		conn, e := n.listener.Accept()
+		if e != nil {
+			select {
+			case <-n.done:
+				return
+			default:
+				continue
+			}
+		}
+		go n.serveConn(conn)
+	}
+}
+
+// serveConn answers the requests that come in on conn, until the peer closes it
+// or the node stops
+func (n *Node) serveConn(conn net.Conn) {
+	n.connMu.Lock()
+	n.conns[conn] = true
+	n.connMu.Unlock()
+	select {
+	case <-n.done:
+		conn.SetReadDeadline(time.Now())
+	default:
+	}
+
+	n.server.ServeCodec(newServerCodec(conn))
+
+	n.connMu.Lock()
+	delete(n.conns, conn)
+	n.connMu.Unlock()
+}
+
+// Done is closed once the node has left the ring
+func (n *Node) Done() <-chan struct{} {
+	return n.done
+}
+
+// Leave hands the node's data to its successor, splices it out of the ring
+// and stops accepting connections.
+func (n *Node) Leave() error {
+	var tmp ChordNode
+	self, succ, pred := n.neighbours()
+
+	if succ.NodeID != self.NodeID {
+		n.dataMu.Lock()
+		transfer, e := n.takeRange(ID{}, ID{})
+		n.dataMu.Unlock()
+		if e != nil {
+			return e
+		}
+
+		if e := n.call(n.ctx, succ, "JRPC.DATA_TRANSFER_FROM_PREDECESSOR", transfer, &tmp); e != nil {
+			//keep the data; we have not left yet
+			n.dataMu.Lock()
+			if e := n.putAll(transfer); e != nil {
+				log.Println("leave:", e)
+			}
+			n.dataMu.Unlock()
+			return e
+		}
+		if e := n.call(n.ctx, succ, "JRPC.NOTIFY_PREDECESSOR", pred, &tmp); e != nil {
+			return e
+		}
+		if e := n.call(n.ctx, pred, "JRPC.NOTIFY_SUCCESSOR", succ, &tmp); e != nil {
+			return e
+		}
+
+		if e := n.FIX_LOCAL_FINGER(n.ctx, self, succ); e != nil {
+			//the ring is already spliced; stabilize will repair the fingers
+			log.Println("leave:", e)
+		}
+	}
+
+	return n.stop()
+}
+
+// stop serving; the ring has already been told we are gone
+func (n *Node) stop() error {
+	select {
+	case <-n.done:
+		return nil
+	default:
+	}
+	n.flushTouched()
+	n.dataMu.Lock()
+	if e := n.store.Close(); e != nil {
+		log.Println("close store:", e)
+	}
+	n.dataMu.Unlock()
+	close(n.done)
+	n.cancel()
+	n.peers.close()
+
+	//read no more requests, on any connection; those already read are answered
+	n.connMu.Lock()
+	for conn := range n.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	n.connMu.Unlock()
+
+	if n.listener != nil {
+		return n.listener.Close()
+	}
+	return nil
+}
+
+// shows the content of the finger table
+func (n *Node) PRINT_FINGERTABLE() {
+	finger := n.fingers()
+	fmt.Println("Finger Table size: ", len(finger))
+	for index := 0; index < len(finger); index++ {
+		fmt.Println("index value for Finger[", index, "] is:", finger[index])
+	}
+}
+
+/*
+This function is used to index all data stored in the node by key and relation,
+once its store is opened; the store files each triplet under the hashing result
+of its key and relation itself. Single triplets are added and removed with put
+and remove afterwards.
+
+	The caller must hold n.dataMu
+*/
+func (n *Node) KR_Hash_All() error {
+	n.index.reset()
+	return n.store.Scan(ID{}, ID{}, func(t Triplet) bool {
+		n.index.add(t)
+		return true
+	})
+}
+
+// ring position of the compound key [key, rel]
+func (n *Node) krHash(key string, rel string) ID {
+	return KRHash(n.hasher, key, rel, n.bits)
+}
+
+// when a server/node starts up it needs to join the ring, this is where joining the ring happens!
+// bootstrap is the "ip:port" of an existing node in the ring; an empty bootstrap (or our own address) starts a new ring
+func (n *Node) Join(bootstrap string) error {
+
+	self := n.self
+	n.setPredecessor(self)
+	n.setSuccessor(self)
+	fmt.Printf("Initial StartUp of Node with Address: %s:%d & NodeID: %s \n", self.IpAddress, self.Port, self.NodeID)
+
+	finger := make([]ChordNode, 0, n.bits)
+	for i := 0; i < n.bits; i++ {
+		finger = append(finger, self) //initialize finger table
+	}
+
+	// if the node is the first one in the ring, then the starting IPAddress & Port should be equal to Self.IPAddress and Self.Port
+	if bootstrap == "" || bootstrap == n.Address() {
+
+		n.setFingers(finger)
+		//for i:=0;i<len(dict3);i++{
+		//fmt.Printf("%d\n",krhash[i])
+		//}
+		fmt.Printf("First Node in the ring with Address: %s:%d & NodeID: %s \n", self.IpAddress, self.Port, self.NodeID)
+
+	} else { //else, there is at least one other node existing in the ring already which has the starting IPAddress and Port, so connect to that node
+
+		n.setFingers(finger)
+		fmt.Printf("Joining the ring with Address: %s & NodeID: %s \n", bootstrap, self.NodeID)
+
+		//fmt.Printf("Joining the ring with Address: %s:%d & NodeID: %s \n", Self.IpAddress, Self.Port, Self.NodeID)
+		/* Argument 1: The address of one existing node in the ring
+		   Argument 2: The remote method to call
+		   Argument 3: The parameters that will be passed to remote method
+		   Argument 4: A pointer to a defined type that will store the response.
+		*/
+		var reply JoinReply
+		ctx, cancel := context.WithTimeout(n.ctx, interval(n.NodeParams.CallTimeout, 5000))
+		e := n.peers.call(ctx, bootstrap, "JRPC.JOIN", (&Query{ChordNode: self}).within(ctx), &reply)
+		cancel()
+		if e == context.DeadlineExceeded {
+			return errors.New(bootstrap + " did not answer JRPC.JOIN in time")
+		}
+		if e != nil {
+			return e
+		}
+		if reply.Collision {
+			return &CollisionError{NodeID: self.NodeID, Holder: reply.Successor}
+		}
+		successor := reply.Successor
+		n.setSuccessor(successor)
+		fmt.Println("RPC.FindSuccessor() -> Response", successor)
+
+		//tell our successor about us; its old predecessor becomes ours
+		var pred ChordNode
+		if e = n.call(n.ctx, successor, "JRPC.NOTIFY", self, &pred); e != nil {
+			return e
+		}
+		n.setPredecessor(pred)
+
+		//data transfer from its successor
+		var transfer Dict3
+		if e = n.call(n.ctx, successor, "JRPC.DATA_TRANSFER_FROM_SUCCESSOR", self, &transfer); e != nil {
+			return e
+		}
+
+		//write data into a database
+		n.dataMu.Lock()
+		e = n.putAll(transfer)
+		n.dataMu.Unlock()
+		if e != nil {
+			return e
+		}
+
+		//splice ourselves in right away rather than waiting for the predecessor's next stabilize.
+		//A successor that still names us was told of us by an earlier run of this
+		//node; the predecessor still points at us, and notifies us on its next stabilize
+		var x ChordNode
+		if pred != self {
+			if e = n.call(n.ctx, pred, "JRPC.NOTIFY_SUCCESSOR", self, &x); e != nil {
+				return e
+			}
+		}
+
+		//the rest of the finger table is filled in by FIX_FINGERS
+	}
+
+	if n.rebuild {
+		n.rebuildData()
+	}
+
+	go n.maintain()
+
+	//PRINT_FINGERTABLE()
+	//fmt.Println("Finger Table entry for index 0  is: ", Finger[0]) //println finger[i]
+	_, succ, pred := n.neighbours()
+	fmt.Printf("Predecessor: %s:%d | NodeID: %s \n", pred.IpAddress, pred.Port, pred.NodeID)
+	fmt.Printf("Successor:    %s:%d | NodeID: %s \n", succ.IpAddress, succ.Port, succ.NodeID)
+	return nil
+}
+
+// CLOSEST_PRECEDING_NODE answers with the farthest finger in (Self, request) that
+// is not suspect, scanning from the last finger back to the first; Self if there
+// is none
+func (n *Node) CLOSEST_PRECEDING_NODE(request *ChordNode, response *ChordNode) error {
+	finger := n.fingers()
+	for i := len(finger) - 1; i >= 0; i-- {
+		f := finger[i]
+		if f.NodeID.Between(n.self.NodeID, request.NodeID) && !n.isSuspect(f) {
+			*response = f
+			return nil
+		}
+	}
+	*response = n.self
+	return nil
+}
+
+// Periodically verify our immediate successor and tell it about us
+func (n *Node) STABILIZE() error {
+	//take over what failed nodes before us left in our range, if a NOTIFY has not
+	n.promoteReplicas(ChordNode{})
+
+	var x ChordNode
+	self, succ, pred := n.neighbours()
+
+	if succ == self && pred == self {
+		//alone, or every neighbour we knew of has failed; wait for a NOTIFY
+		return nil
+	}
+	if succ == self {
+		//every successor we knew of has failed, or was wrongly suspected: our own
+		//successor's predecessor is ourselves, and so the step below, with x our
+		//predecessor, gives it as the successor
+		succ = pred
+		n.setSuccessor(succ)
+	}
+	if e := n.call(n.ctx, succ, "JRPC.GET_PREDECESSOR", self, &x); e != nil {
+		//the successor has been dropped by n.call; the next round uses its replacement
+		return e
+	}
+
+	//adopt successor.predecessor if it sits between us and our successor
+	if x != self && x.NodeID.Between(self.NodeID, succ.NodeID) {
+		succ = x
+		n.setSuccessor(succ)
+	}
+
+	//take over our successor's list, so we can skip past it if it fails
+	var list []ChordNode
+	if e := n.call(n.ctx, succ, "JRPC.GET_SUCCESSOR_LIST", self, &list); e != nil {
+		return e
+	}
+	n.setSuccessorList(succ, list)
+
+	//notify the successor, n might be its predecessor
+	if e := n.call(n.ctx, succ, "JRPC.NOTIFY", self, &x); e != nil {
+		return e
+	}
+	return n.syncReplicas()
+}
+
+// Refresh one finger table entry per call, cycling through the table
+func (n *Node) FIX_FINGERS() error {
+
+	n.mu.Lock()
+	next := n.next
+	n.next = (n.next + 1) % n.bits
+	n.mu.Unlock()
+
+	var t ChordNode
+	var s ChordNode
+	t.NodeID = n.self.NodeID.Add(PowerOfTwo(next), n.bits) //calculate id for this row
+
+	if e := n.lookup(n.ctx, "", &t, &s); e != nil {
+		return e
+	}
+
+	n.mu.Lock()
+	n.finger[next] = s
+	n.mu.Unlock()
+	return nil
+}
+
+// Clear the predecessor if it no longer answers, so a live node can take its place through NOTIFY
+func (n *Node) CHECK_PREDECESSOR() error {
+
+	var x ChordNode
+	self, _, pred := n.neighbours()
+	if pred == self {
+		return nil
+	}
+
+	//a failed call marks pred suspect, which also clears it as our predecessor
+	e := n.call(n.ctx, pred, "JRPC.PING", self, &x)
+	if e != nil {
+		fmt.Printf("Predecessor %s:%d | NodeID: %s is not responding \n", pred.IpAddress, pred.Port, pred.NodeID)
+	}
+	return e
+}
+
+// Background ring maintenance; runs until the node leaves
+func (n *Node) maintain() {
+	stabilize := time.NewTicker(interval(n.NodeParams.StabilizeInterval, 1000))
+	fixFingers := time.NewTicker(interval(n.NodeParams.FixFingersInterval, 500))
+	checkPredecessor := time.NewTicker(interval(n.NodeParams.CheckPredecessorInterval, 2000))
+	flush := time.NewTicker(interval(n.NodeParams.FlushInterval, 5000))
+	defer stabilize.Stop()
+	defer fixFingers.Stop()
+	defer checkPredecessor.Stop()
+	defer flush.Stop()
+
+	for {
+		select {
+		case <-n.done:
+			return
+		case <-stabilize.C:
+			if e := n.STABILIZE(); e != nil {
+				log.Println("stabilize:", e)
+			}
+		case <-fixFingers.C:
+			if e := n.FIX_FINGERS(); e != nil {
+				log.Println("fix fingers:", e)
+			}
+		case <-checkPredecessor.C:
+			n.CHECK_PREDECESSOR()
+		case <-flush.C:
+			n.flushTouched()
+		}
+	}
+}
+
+// interval in milliseconds from the config file, or def when it is not set
+func interval(ms int, def int) time.Duration {
+	if ms <= 0 {
+		ms = def
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+func (n *Node) FIX_LOCAL_FINGER(ctx context.Context, N ChordNode, S ChordNode) error {
+
+	var Init_Self ChordNode
+	Init_Self = N
+
+	var Init_Successor ChordNode
+	Init_Successor = S
+
+	var p ChordNode
+	var s ChordNode
+	var t ChordNode
+
+	finger := n.fingers()
+
+	for i := 0; i < n.bits; i++ { //one row per bit of the ring: there are (at most) m rows/entries in the finger table
+		p = N
+		s = S
+
+		t.NodeID = p.NodeID.Add(PowerOfTwo(i), n.bits) //calculate id for each row
+		//fmt.Printf("%d: %d,%d,%d\n",i, t.NodeID,p.NodeID, s.NodeID)
+		//fmt.Printf("%d\n",t.NodeID)
+
+		if i == 0 {
+			finger[i] = s // the first row of finger table is its successor
+			continue
+		} else {
+
+			for {
+				//this part is used to find the successor
+				if t.NodeID == p.NodeID {
+					finger[i] = p
+					break
+				} else if t.NodeID.In(p.NodeID, s.NodeID) {
+					finger[i] = s
+					break
+				}
+				p = s
+
+				if s.NodeID != Init_Self.NodeID {
+					if e := n.call(ctx, s, "JRPC.GET_SUCCESSOR", t, &s); e != nil {
+						return e
+					}
+				} else {
+					s = Init_Successor
+				}
+			}
+		}
+		//count = count + 1
+	}
+	n.setFingers(finger)
+
+	// fix the finger table
+	for {
+		//fmt.Printf("%d\n",count)
+		N = S
+
+		if N.NodeID == Init_Self.NodeID { //which means we already fixed all the nodes
+			break
+		}
+
+		if e := n.call(ctx, N, "JRPC.GET_SUCCESSOR", Init_Self, &S); e != nil {
+			return e
+		}
+
+		var chordarray ChordArray
+		chordarray.Self = Init_Self
+		chordarray.Successor = Init_Successor
+		if e := n.call(ctx, N, "JRPC.FIX_FINGER", &chordarray, &t); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// update the database after the data has changed in bulk; single writes are
+// durable as they are made. The caller must hold n.dataMu
+func (n *Node) rewrite() {
+	if e := n.store.Snapshot(); e != nil {
+		log.Println("rewrite:", e)
+	}
+}
+
+// touch records a read of t, and sets its access time. The new access time
+// reaches the store with flushTouched, not right away; until then get and scan
+// return it. The caller must hold n.dataMu
+func (n *Node) touch(t *Triplet) {
+	t.Value.Accessed = time.Now().UTC().Truncate(time.Second)
+	n.touched[keyRel{t.Key, t.Relation}] = t.Value.Accessed
+}
+
+// flushTouched writes the access times recorded by touch to the store, and to
+// the replicas, so a copy taken over after a failure is not purged as unread
+func (n *Node) flushTouched() {
+	var flushed Dict3
+	n.dataMu.Lock()
+	for kr, at := range n.touched {
+		t, ok, e := n.store.Get(kr.key, kr.rel)
+		if ok {
+			t.Value.Accessed = at
+			if e = n.store.Put(t); e == nil {
+				flushed = append(flushed, t)
+			}
+		}
+		if e != nil {
+			log.Println("flush access times:", e)
+			break
+		}
+		delete(n.touched, kr)
+	}
+	n.dataMu.Unlock()
+
+	if len(flushed) > 0 {
+		n.replicate(n.ctx, "insertOrUpdate", flushed...)
+	}
+}
@@ -0,0 +1,278 @@
+package chord
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// freePort returns a local TCP port nothing listens on
+func freePort(t testing.TB) int {
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// startRing starts size nodes in this process, each with an in-memory store, and
+// joins them into one ring. The nodes are stopped when the test ends.
+func startRing(t testing.TB, size int) []*Node {
+	return startRingAt(t, make([]string, size))
+}
+
+// startRingAt starts a ring of nodes with the given IDs; an empty one is hashed
+// from the node's address
+func startRingAt(t testing.TB, ids []string) []*Node {
+	var nodes []*Node
+	t.Cleanup(func() {
+		for _, n := range nodes {
+			n.stop()
+		}
+	})
+	for i, id := range ids {
+		c := ConfigParamsType{
+			NodeID:                   id,
+			Protocol:                 "tcp",
+			IpAddress:                "127.0.0.1",
+			Port:                     freePort(t),
+			StabilizeInterval:        50,
+			FixFingersInterval:       20,
+			CheckPredecessorInterval: 200,
+			RingBits:                 16,
+		}
+		c.PersistentStorageContainer.Type = "memory"
+		n := NewNode(c)
+		if e := n.Start(); e != nil {
+			t.Fatal(e)
+		}
+		nodes = append(nodes, n)
+		bootstrap := ""
+		if i > 0 {
+			bootstrap = nodes[0].Address()
+		}
+		if e := n.Join(bootstrap); e != nil {
+			t.Fatal(e)
+		}
+	}
+	waitStable(t, nodes)
+	return nodes
+}
+
+// waitStable waits until every node's successor and predecessor are its
+// neighbours on the ring
+func waitStable(t testing.TB, nodes []*Node) {
+	ring := make([]ChordNode, len(nodes))
+	for i, n := range nodes {
+		ring[i] = n.self
+	}
+	sort.Slice(ring, func(i, j int) bool { return ring[i].NodeID.Cmp(ring[j].NodeID) < 0 })
+	at := make(map[ChordNode]int)
+	for i, s := range ring {
+		at[s] = i
+	}
+
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		stable := true
+		for _, n := range nodes {
+			self, succ, pred := n.neighbours()
+			i := at[self]
+			if succ != ring[(i+1)%len(ring)] || pred != ring[(i+len(ring)-1)%len(ring)] {
+				stable = false
+				break
+			}
+		}
+		if stable {
+			return
+		}
+	}
+	t.Fatal("the ring did not stabilize")
+}
+
+// Concurrent writes and reads through every node of a ring, to be run with -race
+func TestRingConcurrentInsertLookup(t *testing.T) {
+	nodes := startRing(t, 5)
+
+	const workers, keys = 8, 20
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := nodes[w%len(nodes)].jrpc
+			for i := 0; i < keys; i++ {
+				key := "k" + strconv.Itoa(w) + "_" + strconv.Itoa(i)
+				var g Get
+				insert := &Operation{Method: "insert", Params: DICT3Item{key, "rel", map[string]interface{}{"content": key, "permission": "RW"}}}
+				if e := r.INSERT(insert, &g); e != nil || g.Error != nil {
+					t.Errorf("insert %s: %v %v", key, e, g.Error)
+					return
+				}
+				var l Get
+				lookup := &Operation{Method: "lookup", Params: DICT3Item{key, "rel"}}
+				if e := r.LOOKUP(lookup, &l); e != nil || l.Error != nil || len(l.Result) != 1 {
+					t.Errorf("lookup %s: %v %v %v", key, e, l.Error, l.Result)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	//every node finds every triplet, wherever it was written
+	for _, n := range nodes {
+		for w := 0; w < workers; w++ {
+			for i := 0; i < keys; i++ {
+				key := "k" + strconv.Itoa(w) + "_" + strconv.Itoa(i)
+				var l Get
+				if e := n.jrpc.LOOKUP(&Operation{Method: "lookup", Params: DICT3Item{key, "rel"}}, &l); e != nil || len(l.Result) != 1 {
+					t.Fatalf("%s:%d cannot find %s: %v %v", n.self.IpAddress, n.self.Port, key, e, l.Error)
+				}
+			}
+		}
+	}
+}
+
+// owner returns the node of nodes that owns key and rel
+func owner(nodes []*Node, key string, rel string) *Node {
+	for _, n := range nodes {
+		if n.router.Owns(n.krHash(key, rel)) {
+			return n
+		}
+	}
+	return nil
+}
+
+// A read's access time reaches the replicas, not just the owner's store
+func TestAccessTimeReachesReplicas(t *testing.T) {
+	nodes := startRing(t, 3)
+	r := nodes[0].jrpc
+	var g Get
+	if e := r.INSERT(&Operation{Params: DICT3Item{"k", "r", map[string]interface{}{"content": "x", "permission": "RW"}}}, &g); e != nil || g.Error != nil {
+		t.Fatal(e, g.Error)
+	}
+	o := owner(nodes, "k", "r")
+
+	//access times are kept to the second
+	time.Sleep(1100 * time.Millisecond)
+	var l Get
+	if e := r.LOOKUP(&Operation{Params: DICT3Item{"k", "r"}}, &l); e != nil || len(l.Result) != 1 {
+		t.Fatal(e, l.Error)
+	}
+	read := l.Result[0].(Triplet).Value.Accessed
+	o.flushTouched()
+
+	copies := 0
+	for _, n := range nodes {
+		if n == o {
+			continue
+		}
+		n.dataMu.Lock()
+		for _, item := range n.replicas[o.self] {
+			if item.Key == "k" && item.Relation == "r" {
+				copies++
+				if !item.Value.Accessed.Equal(read) {
+					t.Errorf("replica on %s:%d was accessed %v, not %v", n.self.IpAddress, n.self.Port, item.Value.Accessed, read)
+				}
+			}
+		}
+		n.dataMu.Unlock()
+	}
+	if copies != 2 {
+		t.Errorf("%d replicas, not 2", copies)
+	}
+}
+
+// A node that wrongly gives up its only peer as its successor takes it back from
+// its predecessor, rather than staying split from the ring
+func TestStabilizeAdoptsPredecessor(t *testing.T) {
+	nodes := startRing(t, 2)
+	nodes[0].suspect(nodes[1].self)
+	if _, succ, _ := nodes[0].neighbours(); succ != nodes[0].self {
+		t.Fatal("suspect did not drop the successor")
+	}
+	waitStable(t, nodes)
+}
+
+// A node whose data cannot be handed over on shutdown keeps it
+func TestShutdownKeepsDataOnFailedTransfer(t *testing.T) {
+	n := startRing(t, 1)[0]
+	var g Get
+	if e := n.jrpc.INSERT(&Operation{Params: DICT3Item{"k", "r", map[string]interface{}{"content": "x", "permission": "RW"}}}, &g); e != nil || g.Error != nil {
+		t.Fatal(e, g.Error)
+	}
+	//a successor that is not there
+	n.setSuccessor(ChordNode{NodeID: n.self.NodeID.Add(IDFromUint64(1), n.bits), IpAddress: "127.0.0.1", Port: freePort(t)})
+
+	var reply ChordNode
+	if e := n.jrpc.SHUTDOWN_DATA(&ChordNode{Port: 1}, &reply); e == nil {
+		t.Fatal("the transfer to a dead successor succeeded")
+	}
+	if _, ok := stored(n, "k", "r"); !ok {
+		t.Error("the data was lost with the transfer")
+	}
+}
+
+// hangingPeer accepts connections and never answers on them, until the test ends
+func hangingPeer(t *testing.T) ChordNode {
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	var conns []net.Conn
+	var mu sync.Mutex
+	t.Cleanup(func() {
+		l.Close()
+		mu.Lock()
+		for _, c := range conns {
+			c.Close()
+		}
+		mu.Unlock()
+	})
+	go func() {
+		for {
+			c, e := l.Accept()
+			if e != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+		}
+	}()
+	return ChordNode{IpAddress: "127.0.0.1", Port: l.Addr().(*net.TCPAddr).Port}
+}
+
+// A FIND_SUCCESSOR passed on to a peer that hangs gives up when the node that
+// asked does, not CallTimeout later: the time left goes along with each hop
+func TestFindSuccessorCarriesDeadline(t *testing.T) {
+	n := startRingAt(t, []string{"1000"})[0]
+	h := hangingPeer(t)
+	h.NodeID = IDFromUint64(2000)
+	n.setSuccessor(h)
+
+	p := newPool(func(ctx context.Context, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	})
+	defer p.close()
+
+	//the asker has no deadline of its own; only the one sent along ends the query
+	start := time.Now()
+	var s ChordNode
+	e := p.call(context.Background(), n.Address(), "JRPC.FIND_SUCCESSOR", &Query{ChordNode{NodeID: IDFromUint64(3000)}, 300}, &s)
+	if e == nil {
+		t.Fatalf("the query answered %v through a peer that hangs", s)
+	}
+	if took := time.Since(start); took > 2*time.Second {
+		t.Errorf("the query gave up after %v, not the 300ms it was sent with", took)
+	}
+	if !strings.HasSuffix(e.Error(), " timed out") {
+		t.Errorf("%v, not a timeout", e)
+	}
+}
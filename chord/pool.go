@@ -0,0 +1,232 @@
+package chord
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+pool keeps connections to other nodes open between calls, so that a node does not
+dial a peer for every call it makes. A connection is taken for one call at a time
+and put back when the call is done; up to poolMaxIdle of them are kept per peer,
+each for up to poolIdleTimeout. An idle connection is checked before it is
+reused: one that the peer hung up on, or that broke, while it was idle is closed
+and another taken. One found shut down by the call itself, which was then never
+sent, is replaced by a new one, and the call made on that; a call that may have
+been sent is never made again, as not all of them are safe to repeat. A call
+that runs past the deadline of its context is given up, and its connection
+closed. Shared by everything a node calls; safe for concurrent use.
+*/
+type pool struct {
+	dial func(ctx context.Context, addr string) (net.Conn, error)
+
+	mu     sync.Mutex
+	idle   map[string][]idleClient //by peer address, the most recently used last
+	closed bool
+	done   chan struct{}
+}
+
+// A connection to a peer, with the client making calls on it
+type peerConn struct {
+	client *rpc.Client
+	conn   *watchedConn
+}
+
+// watchedConn notes a read on the connection that fails. The client is always
+// reading, for the answer to the next call, so a connection that the peer hung
+// up on or that broke while it was idle is known to be broken before a call is
+// made on it.
+type watchedConn struct {
+	net.Conn
+	broken int32
+}
+
+func (c *watchedConn) Read(b []byte) (int, error) {
+	n, e := c.Conn.Read(b)
+	if e != nil {
+		atomic.StoreInt32(&c.broken, 1)
+	}
+	return n, e
+}
+
+// healthy reports whether no read on c has failed
+func (c *watchedConn) healthy() bool {
+	return atomic.LoadInt32(&c.broken) == 0
+}
+
+// A connection waiting for the next call to its peer, and since when
+type idleClient struct {
+	peerConn
+	since time.Time
+}
+
+// idle connections kept per peer
+const poolMaxIdle = 4
+
+// how long an idle connection is kept
+const poolIdleTimeout = time.Minute
+
+func newPool(dial func(ctx context.Context, addr string) (net.Conn, error)) *pool {
+	p := &pool{dial: dial, idle: make(map[string][]idleClient), done: make(chan struct{})}
+	go p.sweep()
+	return p
+}
+
+// call makes a call to the peer at addr on an idle connection, or a new one. It
+// returns ctx.Err() if ctx is done before the peer answers.
+func (p *pool) call(ctx context.Context, addr string, method string, args interface{}, reply interface{}) error {
+	for {
+		c, reused, e := p.get(ctx, addr)
+		if e != nil {
+			return e
+		}
+		e = c.call(ctx, method, args, reply)
+		if e == rpc.ErrShutdown && reused {
+			//the connection broke between its check and the call, which was not sent
+			c.client.Close()
+			continue
+		}
+		if _, ok := e.(rpc.ServerError); e == nil || ok {
+			p.put(addr, c)
+		} else {
+			c.client.Close()
+		}
+		return e
+	}
+}
+
+// call makes one call on c. The deadline of ctx is set on the connection, so that
+// a peer that stops reading cannot block the request from being sent either.
+func (c peerConn) call(ctx context.Context, method string, args interface{}, reply interface{}) error {
+	deadline, _ := ctx.Deadline()
+	c.conn.SetDeadline(deadline)
+	call := c.client.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+	case <-ctx.Done():
+		//the answer may never come; closing the connection ends the call
+		c.client.Close()
+		<-call.Done
+	}
+	if _, ok := call.Error.(rpc.ServerError); call.Error == nil || ok {
+		//the peer answered in time
+		c.conn.SetDeadline(time.Time{})
+		return call.Error
+	}
+	if e := ctxErr(ctx); e != nil {
+		return e
+	}
+	return call.Error
+}
+
+// ctxErr returns ctx.Err(), or context.DeadlineExceeded once the deadline of ctx
+// has passed: a connection's deadline, or a dial's, can go off before the
+// context's own timer does
+func ctxErr(ctx context.Context) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// get takes a healthy idle connection to addr, closing any broken ones on the
+// way, or dials a new one; reused tells which
+func (p *pool) get(ctx context.Context, addr string) (c peerConn, reused bool, e error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return c, false, errors.New("node is stopping: no more calls to " + addr)
+	}
+	for idle := p.idle[addr]; len(idle) > 0; idle = p.idle[addr] {
+		c = idle[len(idle)-1].peerConn
+		p.idle[addr] = idle[:len(idle)-1]
+		if c.conn.healthy() {
+			p.mu.Unlock()
+			return c, true, nil
+		}
+		c.client.Close()
+	}
+	p.mu.Unlock()
+	c, e = p.connect(ctx, addr)
+	return c, false, e
+}
+
+// connect dials a new connection to addr
+func (p *pool) connect(ctx context.Context, addr string) (peerConn, error) {
+	conn, e := p.dial(ctx, addr)
+	if e != nil {
+		if e := ctxErr(ctx); e != nil {
+			return peerConn{}, e
+		}
+		return peerConn{}, e
+	}
+	watched := &watchedConn{Conn: conn}
+	return peerConn{jsonrpc.NewClient(watched), watched}, nil
+}
+
+// put keeps c for the next call to addr, unless enough are kept already
+func (p *pool) put(addr string, c peerConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || len(p.idle[addr]) >= poolMaxIdle {
+		c.client.Close()
+		return
+	}
+	p.idle[addr] = append(p.idle[addr], idleClient{c, time.Now()})
+}
+
+// sweep closes the connections that have been idle for poolIdleTimeout, until
+// the pool is closed
+func (p *pool) sweep() {
+	ticker := time.NewTicker(poolIdleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		for addr, idle := range p.idle {
+			//the least recently used come first
+			stale := 0
+			for stale < len(idle) && time.Since(idle[stale].since) >= poolIdleTimeout {
+				idle[stale].client.Close()
+				stale++
+			}
+			if stale == len(idle) {
+				delete(p.idle, addr)
+			} else {
+				p.idle[addr] = idle[stale:]
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// close closes the idle connections, and any that are put back later
+func (p *pool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	for _, idle := range p.idle {
+		for _, c := range idle {
+			c.client.Close()
+		}
+	}
+	p.idle = nil
+	close(p.done)
+}
@@ -0,0 +1,203 @@
+package chord
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type Echo struct {
+	calls *int32
+}
+
+func (x Echo) Echo(args *string, reply *string) error {
+	atomic.AddInt32(x.calls, 1)
+	*reply = *args
+	return nil
+}
+
+// echoServer serves Echo.Echo on a local port until the test ends
+type echoServer struct {
+	addr  string
+	calls int32
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func startEcho(t *testing.T) *echoServer {
+	s := &echoServer{}
+	server := rpc.NewServer()
+	server.Register(Echo{&s.calls})
+	l, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatal(e)
+	}
+	t.Cleanup(func() { l.Close() })
+	s.addr = l.Addr().String()
+	go func() {
+		for {
+			conn, e := l.Accept()
+			if e != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+		}
+	}()
+	return s
+}
+
+// hangUp closes the server's end of every connection
+func (s *echoServer) hangUp() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func echo(p *pool, addr string, args string) error {
+	var reply string
+	e := p.call(context.Background(), addr, "Echo.Echo", &args, &reply)
+	if e == nil && reply != args {
+		e = errors.New("echo: " + reply)
+	}
+	return e
+}
+
+// An idle connection the peer hung up on is found broken before it is used
+func TestPoolChecksIdleConnections(t *testing.T) {
+	s := startEcho(t)
+	dials := 0
+	p := newPool(func(ctx context.Context, addr string) (net.Conn, error) {
+		dials++
+		return (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	})
+	defer p.close()
+
+	if e := echo(p, s.addr, "one"); e != nil {
+		t.Fatal(e)
+	}
+	s.hangUp()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		p.mu.Lock()
+		healthy := p.idle[s.addr][0].conn.healthy()
+		p.mu.Unlock()
+		if !healthy {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the hang-up was not noticed")
+		}
+	}
+	c, reused, e := p.get(context.Background(), s.addr)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if reused {
+		t.Error("a broken idle connection was handed out")
+	}
+	p.put(s.addr, c)
+	if e := echo(p, s.addr, "two"); e != nil {
+		t.Fatal(e)
+	}
+	if dials != 2 || atomic.LoadInt32(&s.calls) != 2 {
+		t.Errorf("%d dials and %d calls, not 2 and 2", dials, s.calls)
+	}
+}
+
+// A connection that drops the moment a request has been written to it
+type droppingConn struct {
+	net.Conn
+	drop *int32
+}
+
+func (c droppingConn) Write(b []byte) (int, error) {
+	n, e := c.Conn.Write(b)
+	if atomic.LoadInt32(c.drop) != 0 {
+		c.Conn.Close()
+	}
+	return n, e
+}
+
+// A call that may have reached the peer is not made again on a new connection:
+// not every call is safe to repeat
+func TestPoolDoesNotRepeatSentCalls(t *testing.T) {
+	s := startEcho(t)
+	var drop int32
+	dials := 0
+	p := newPool(func(ctx context.Context, addr string) (net.Conn, error) {
+		dials++
+		conn, e := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+		return droppingConn{conn, &drop}, e
+	})
+	defer p.close()
+
+	if e := echo(p, s.addr, "one"); e != nil {
+		t.Fatal(e)
+	}
+	atomic.StoreInt32(&drop, 1)
+	if e := echo(p, s.addr, "two"); e == nil {
+		t.Fatal("a call on a connection that dropped succeeds")
+	}
+	if dials != 1 {
+		t.Errorf("the call was made again on %d new connections", dials-1)
+	}
+}
+
+// dropIdle closes the idle connections of p, so that the next call dials, as
+// every call did before the pool
+func dropIdle(p *pool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, idle := range p.idle {
+		for _, c := range idle {
+			c.client.Close()
+		}
+	}
+	p.idle = make(map[string][]idleClient)
+}
+
+// Lookups of triplets on other nodes, which route through the ring and read from
+// the owner, on pooled connections and on a new connection for every call
+func BenchmarkRemoteLookup(b *testing.B) {
+	nodes := startRing(b, 5)
+	var keys []string
+	for i := 0; len(keys) < 50; i++ {
+		key := "k" + strconv.Itoa(i)
+		if owner(nodes, key, "r") == nodes[0] {
+			continue
+		}
+		var g Get
+		d := &Operation{Params: DICT3Item{key, "r", map[string]interface{}{"content": key, "permission": "RW"}}}
+		if e := nodes[0].jrpc.INSERT(d, &g); e != nil || g.Error != nil {
+			b.Fatal(e, g.Error)
+		}
+		keys = append(keys, key)
+	}
+
+	lookup := func(b *testing.B, fresh bool) {
+		for i := 0; i < b.N; i++ {
+			if fresh {
+				dropIdle(nodes[0].peers)
+			}
+			var g Get
+			if e := nodes[0].jrpc.LOOKUP(&Operation{Params: DICT3Item{keys[i%len(keys)], "r"}}, &g); e != nil || g.Error != nil {
+				b.Fatal(e, g.Error)
+			}
+		}
+	}
+	b.Run("pooled", func(b *testing.B) { lookup(b, false) })
+	b.Run("dial", func(b *testing.B) { lookup(b, true) })
+}
@@ -0,0 +1,216 @@
+package chord
+
+import (
+	"context"
+	"errors"
+	"net/rpc"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// how long a peer that failed a call is routed around before we try it again
+const suspectTimeout = 10 * time.Second
+
+// call makes a JRPC call to node, on a connection from the node's pool, giving up
+// after CallTimeout or when ctx is done. A client operation sent along carries
+// the time left, so the peer gives up on it when we do. A node that cannot be
+// reached, or does not answer in time, is marked suspect so routing avoids it; a
+// successful call clears the mark.
+func (n *Node) call(ctx context.Context, node ChordNode, method string, args interface{}, reply interface{}) error {
+	timeout := interval(n.NodeParams.CallTimeout, 5000)
+	//the peer is only to blame if it ran out of its own time, not the request's
+	blame := true
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= timeout {
+		blame = false
+	}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	switch a := args.(type) {
+	case *Operation:
+		args = a.within(callCtx)
+	case *Query:
+		args = a.within(callCtx)
+	case *ChordArray:
+		c := *a
+		c.Timeout = timeLeft(callCtx, a.Timeout)
+		args = &c
+	}
+
+	e := n.peers.call(callCtx, node.IpAddress+":"+strconv.Itoa(node.Port), method, args, reply)
+	switch {
+	case e == nil:
+		n.clearSuspect(node)
+		return nil
+	case e == context.Canceled:
+		return errors.New(method + ": node is stopping")
+	case e == context.DeadlineExceeded:
+		if blame {
+			n.suspect(node)
+		}
+		return &TimeoutError{Peer: node, Method: method}
+	}
+	//an error returned by the handler itself means the peer is alive
+	if _, ok := e.(rpc.ServerError); !ok {
+		n.suspect(node)
+	}
+	return e
+}
+
+// callContext returns the context for a handler that is not given a client
+// operation to take its deadline from; the caller waits CallTimeout for it
+func (n *Node) callContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(n.ctx, passOn(interval(n.NodeParams.CallTimeout, 5000)))
+}
+
+// requestContext returns the context a handler of d works under: the time left
+// that came with d from another node, or RequestTimeout for a request straight
+// from a client. Another handler on this node passed d on shares its deadline.
+func (n *Node) requestContext(d *Operation) (context.Context, context.CancelFunc) {
+	if d.ctx != nil {
+		return context.WithCancel(d.ctx)
+	}
+	timeout := interval(n.NodeParams.RequestTimeout, 30000)
+	if d.Timeout > 0 {
+		timeout = time.Duration(d.Timeout) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(n.ctx, timeout)
+	d.ctx = ctx
+	return ctx, cancel
+}
+
+// queryContext returns the context for a handler of a query that came with
+// timeout milliseconds left, or with none, when the caller waits CallTimeout
+func (n *Node) queryContext(timeout int64) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return n.callContext()
+	}
+	return context.WithTimeout(n.ctx, time.Duration(timeout)*time.Millisecond)
+}
+
+// within returns a copy of d to send to another node, with the time left before
+// the deadline of ctx
+func (d *Operation) within(ctx context.Context) *Operation {
+	c := *d
+	c.Timeout = timeLeft(ctx, d.Timeout)
+	return &c
+}
+
+// within returns a copy of q to send to another node, with the time left before
+// the deadline of ctx
+func (q *Query) within(ctx context.Context) *Query {
+	c := *q
+	c.Timeout = timeLeft(ctx, q.Timeout)
+	return &c
+}
+
+// timeLeft returns the milliseconds a peer is given to answer a call made under
+// ctx, or timeout if ctx has no deadline
+func timeLeft(ctx context.Context, timeout int64) int64 {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return timeout
+	}
+	if left := int64(passOn(time.Until(deadline)) / time.Millisecond); left > 0 {
+		return left
+	}
+	return 1
+}
+
+// passOn returns how much of the time left a node gives a peer that works for
+// it: a tenth less, so that the peer answers, if only that a call of its own
+// timed out, before the node gives up on it
+func passOn(left time.Duration) time.Duration {
+	return left - left/10
+}
+
+// unreachable reports whether e means the peer could not be reached at all, as
+// opposed to the peer answering with an error
+func unreachable(e error) bool {
+	_, ok := e.(rpc.ServerError)
+	return e != nil && !ok
+}
+
+// timedOut reports whether e means a call, here or on a node further along,
+// did not get an answer in time
+func timedOut(e error) bool {
+	if _, ok := e.(*TimeoutError); ok {
+		return true
+	}
+	_, ok := e.(rpc.ServerError)
+	return ok && strings.HasSuffix(e.Error(), " timed out")
+}
+
+// unreachableCode is the error code for a client whose request failed because
+// of e, an error that kept its owner from being reached
+func unreachableCode(e error) int {
+	if timedOut(e) {
+		return Timeout
+	}
+	return OwnerUnreachable
+}
+
+// suspect marks node as unreachable and drops it from the routing state
+func (n *Node) suspect(node ChordNode) {
+	if node == n.self {
+		return
+	}
+	n.mu.Lock()
+	if n.suspects == nil {
+		n.suspects = make(map[ChordNode]time.Time)
+	}
+	n.suspects[node] = time.Now()
+
+	//replace the dead node in the finger table with the next finger after it
+	for i := len(n.finger) - 1; i >= 0; i-- {
+		if n.finger[i] == node {
+			if i+1 < len(n.finger) && n.finger[i+1] != node {
+				n.finger[i] = n.finger[i+1]
+			} else {
+				n.finger[i] = n.self
+			}
+		}
+	}
+	wasPredecessor := n.predecessor == node
+	if wasPredecessor {
+		n.predecessor = n.self
+	}
+	//promote the next live entry of the successor list, or failing that the next
+	//finger; the next stabilize repairs the link properly
+	successors := n.successors[:0:0]
+	for _, s := range n.successors {
+		if s != node {
+			successors = append(successors, s)
+		}
+	}
+	n.successors = successors
+	if n.successor == node {
+		n.successor = n.self
+		if len(n.successors) > 0 {
+			n.successor = n.successors[0]
+		} else if len(n.finger) > 0 {
+			n.successor = n.finger[0]
+		}
+		n.spliceSuccessor()
+	}
+	n.mu.Unlock()
+
+	//the range node owned is ours now
+	if wasPredecessor {
+		n.promoteReplicas(node)
+	}
+}
+
+func (n *Node) clearSuspect(node ChordNode) {
+	n.mu.Lock()
+	delete(n.suspects, node)
+	n.mu.Unlock()
+}
+
+// isSuspect reports whether node failed a call within the last suspectTimeout
+func (n *Node) isSuspect(node ChordNode) bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	t, ok := n.suspects[node]
+	return ok && time.Since(t) < suspectTimeout
+}
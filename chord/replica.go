@@ -0,0 +1,266 @@
+package chord
+
+import (
+	"context"
+	"log"
+)
+
+// the number of successors each triplet is copied to (k), from the config file
+func (n *Node) replicaCount() int {
+	if n.NodeParams.Replicas <= 0 {
+		return 2
+	}
+	return n.NodeParams.Replicas
+}
+
+// replicaTargets returns the first k successors that are not ourselves
+func (n *Node) replicaTargets() []ChordNode {
+	var targets []ChordNode
+	for _, s := range n.successorList() {
+		if s == n.self || len(targets) == n.replicaCount() {
+			break
+		}
+		targets = append(targets, s)
+	}
+	return targets
+}
+
+// replicate copies writes made on this node to its replicas. A replica that
+// misses them is brought up to date by the next syncReplicas.
+func (n *Node) replicate(ctx context.Context, method string, items ...Triplet) {
+	replica := Replica{Owner: n.self, Method: method, Data: Dict3(items)}
+	for _, s := range n.replicaTargets() {
+		var x ChordNode
+		if e := n.call(ctx, s, "JRPC.REPLICATE", replica, &x); e != nil {
+			log.Println("replicate:", e)
+			n.replicasStale()
+		}
+	}
+}
+
+// replicasStale makes the next stabilize push our whole data set to the replicas
+func (n *Node) replicasStale() {
+	n.mu.Lock()
+	n.replicated = nil
+	n.mu.Unlock()
+}
+
+/*
+syncReplicas pushes the whole data set to the replicas when the set of successors
+holding them has changed, or when the data changed in bulk since the last push.
+Called from STABILIZE.
+*/
+func (n *Node) syncReplicas() error {
+	targets := n.replicaTargets()
+
+	n.mu.RLock()
+	same := len(targets) == len(n.replicated)
+	for i := 0; same && i < len(targets); i++ {
+		same = targets[i] == n.replicated[i]
+	}
+	n.mu.RUnlock()
+	if same {
+		return nil
+	}
+
+	n.dataMu.Lock()
+	data, e := n.scan(ID{}, ID{})
+	n.dataMu.Unlock()
+	if e != nil {
+		return e
+	}
+
+	replica := Replica{Owner: n.self, Method: "sync", Data: data, Targets: targets}
+	for _, s := range targets {
+		var x ChordNode
+		if e := n.call(n.ctx, s, "JRPC.REPLICATE", replica, &x); e != nil {
+			return e
+		}
+	}
+
+	n.mu.Lock()
+	n.replicated = targets
+	n.mu.Unlock()
+	return nil
+}
+
+// promoteReplicas takes over the replicas we hold of triplets that are ours now
+// the predecessor has changed: all of those held for failed, the predecessor that
+// was, and once a new predecessor is known, those of any owner that fall in
+// (pred, self], so that the ranges of several failed nodes in a row are taken
+// over as well. failed is zero when no node is known to have failed.
+func (n *Node) promoteReplicas(failed ChordNode) {
+	n.dataMu.Lock()
+	promoted := n.promote(failed)
+	n.dataMu.Unlock()
+
+	if promoted > 0 {
+		log.Printf("took over %d triplets from the replicas", promoted)
+		n.replicasStale()
+	}
+}
+
+// promote does the work of promoteReplicas, with dataMu held, and returns how
+// many triplets were taken over
+func (n *Node) promote(failed ChordNode) int {
+	lo, hi := n.router.Range()
+	known := lo != hi
+	if failed == (ChordNode{}) && (!known || lo == n.promotedAfter) {
+		return 0
+	}
+
+	promoted := 0
+	var e error
+	for owner, held := range n.replicas {
+		var kept Dict3
+		for _, item := range held {
+			ours := owner == failed || known && n.krHash(item.Key, item.Relation).In(lo, hi)
+			if ours && e == nil {
+				var found bool
+				if _, found, e = n.get(item.Key, item.Relation); e == nil && !found {
+					if e = n.put(item); e == nil {
+						promoted++
+					}
+				}
+				if e == nil {
+					continue
+				}
+				log.Println("take over replicas:", e)
+			}
+			kept = append(kept, item)
+		}
+		if len(kept) == 0 {
+			delete(n.replicas, owner)
+		} else {
+			n.replicas[owner] = kept
+		}
+	}
+	if known && e == nil {
+		n.promotedAfter = lo
+	}
+	if promoted > 0 {
+		n.rewrite()
+	}
+	return promoted
+}
+
+// prune drops the replicas held for owners we are no longer a target of, as
+// told by a sync from owner, which copies its triplets to targets. An owner that
+// has left the ring between owner and us had its range passed to the node after
+// it: if that is us, its replicas are taken over instead. Called with dataMu held.
+func (n *Node) prune(owner ChordNode, targets []ChordNode) {
+	self := n.self
+	j := -1
+	for i, t := range targets {
+		if t == self {
+			j = i
+			break
+		}
+	}
+	if j < 0 {
+		return
+	}
+
+	promoted := 0
+	for held := range n.replicas {
+		if held == owner {
+			continue
+		}
+		if !held.NodeID.Between(owner.NodeID, self.NodeID) {
+			//before owner: we are at least j+2 nodes after held
+			if j+1 >= n.replicaCount() {
+				delete(n.replicas, held)
+			}
+			continue
+		}
+		listed := false
+		next := self //the node after held, which took its range over
+		for _, t := range targets[:j] {
+			if t == held {
+				listed = true
+			}
+			if next == self && t.NodeID.Between(held.NodeID, self.NodeID) {
+				next = t
+			}
+		}
+		if listed {
+			continue
+		}
+		if next == self {
+			promoted += n.promote(held)
+		} else {
+			delete(n.replicas, held)
+		}
+	}
+	if promoted > 0 {
+		log.Printf("took over %d triplets from the replicas", promoted)
+		n.replicasStale()
+	}
+}
+
+/*
+rebuildData fills a store that was found corrupt at startup with the triplets we
+owned before, from the replicas our successors still hold for us; where they
+differ, the last modified copy wins. Triplets a successor has already taken over
+are handed back by Join before this is called.
+*/
+func (n *Node) rebuildData() {
+	self, succ, _ := n.neighbours()
+	n.rebuild = false
+	if succ == self {
+		log.Println("rebuild: there are no other nodes to rebuild the data from")
+		return
+	}
+
+	sources := []ChordNode{succ}
+	var list []ChordNode
+	if e := n.call(n.ctx, succ, "JRPC.GET_SUCCESSOR_LIST", self, &list); e == nil {
+		sources = append(sources, list...)
+	}
+
+	asked := 0
+	rebuilt := 0
+	for _, s := range sources {
+		if s == self || asked == n.replicaCount() {
+			break
+		}
+		asked++
+		var found Dict3
+		if e := n.call(n.ctx, s, "JRPC.REPLICAS_OF", self, &found); e != nil {
+			log.Println("rebuild:", e)
+			continue
+		}
+
+		n.dataMu.Lock()
+		for _, item := range found {
+			old, ok, e := n.get(item.Key, item.Relation)
+			if e == nil && (!ok || item.Value.Modified.After(old.Value.Modified)) {
+				e = n.put(item)
+			}
+			if e != nil {
+				log.Println("rebuild:", e)
+				break
+			}
+			if !ok {
+				rebuilt++
+			}
+		}
+		n.rewrite()
+		n.dataMu.Unlock()
+	}
+
+	log.Printf("rebuilt %d triplets from the replicas on %d nodes", rebuilt, asked)
+	if rebuilt > 0 {
+		n.replicasStale()
+	}
+}
+
+// findItem returns the index of the triplet with the same key and relation as item, or -1
+func findItem(dict3 Dict3, item Triplet) int {
+	for i := 0; i < len(dict3); i++ {
+		if dict3[i].Key == item.Key && dict3[i].Relation == item.Relation {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,108 @@
+package chord
+
+import (
+	"context"
+	"errors"
+)
+
+/*
+Iterative routing. Where FIND_SUCCESSOR hands the query on from node to node, each
+waiting for the next to answer, an iterative lookup is driven by the node that
+makes it: it asks each hop in turn, with NEXT_HOP, for the closest node before the
+ID that the hop knows of, until a hop knows the ID's successor. No hop holds a
+call open for another, the nodes asked make up the route, and a hop that does not
+answer is routed around by the node making the lookup, from the successor list of
+the hop that pointed to it.
+*/
+
+// lookup finds the successor of id with the routing given, "recursive" or
+// "iterative", or with the routing of the config file if none is
+func (n *Node) lookup(ctx context.Context, routing string, id *ChordNode, owner *ChordNode) error {
+	if routing == "" {
+		routing = n.NodeParams.Routing
+	}
+	if routing != "iterative" {
+		return n.findSuccessor(ctx, id, owner)
+	}
+	s, _, e := n.findSuccessorIterative(ctx, *id)
+	if e != nil {
+		return e
+	}
+	*owner = s
+	return nil
+}
+
+// nextHop answers NEXT_HOP for id from our routing state
+func (n *Node) nextHop(id ChordNode) NextHop {
+	if s, ok := n.localSuccessor(&id); ok {
+		return NextHop{Node: s, Done: true}
+	}
+	self, succ, _ := n.neighbours()
+
+	var next ChordNode
+	n.CLOSEST_PRECEDING_NODE(&id, &next)
+	if next == self {
+		next = succ
+	}
+	if next == self {
+		//everyone we knew of has failed; we are the best answer we have
+		return NextHop{Node: self, Done: true}
+	}
+	return NextHop{Node: next, Successors: n.successorList()}
+}
+
+// findSuccessorIterative finds the successor of id by asking each hop itself. The
+// route lists the nodes asked, in order, starting with this one.
+func (n *Node) findSuccessorIterative(ctx context.Context, id ChordNode) (ChordNode, []ChordNode, error) {
+	route := []ChordNode{n.self}
+	asked := map[ChordNode]bool{n.self: true}
+	failed := make(map[ChordNode]bool)
+
+	//the node that gave the step, whose successors are the way round a failed hop
+	from := n.self
+	step := n.nextHop(id)
+	for !step.Done {
+		if failed[step.Node] {
+			var ok bool
+			if step, ok = detour(from, step.Successors, id, failed); !ok {
+				return ChordNode{}, route, errors.New("iterative lookup: no live node after " + from.NodeID.String())
+			}
+			continue
+		}
+		if asked[step.Node] {
+			return ChordNode{}, route, errors.New("iterative lookup: routed back to " + step.Node.NodeID.String())
+		}
+		asked[step.Node] = true
+		route = append(route, step.Node)
+
+		var next NextHop
+		if e := n.call(ctx, step.Node, "JRPC.NEXT_HOP", &Query{ChordNode: id}, &next); e != nil {
+			if !unreachable(e) || ctxErr(ctx) != nil {
+				return ChordNode{}, route, e
+			}
+			//n.call has marked it suspect; keep its list to go round it
+			failed[step.Node] = true
+			continue
+		}
+		from, step = step.Node, next
+	}
+	return step.Node, route, nil
+}
+
+// detour picks the step after from when the node from pointed to has failed: the
+// first live node in its successor list if id is between from and it, and
+// otherwise the farthest live one before id
+func detour(from ChordNode, successors []ChordNode, id ChordNode, failed map[ChordNode]bool) (NextHop, bool) {
+	var best NextHop
+	found := false
+	for _, s := range successors {
+		if s == from || failed[s] {
+			continue
+		}
+		if id.NodeID.In(from.NodeID, s.NodeID) {
+			return NextHop{Node: s, Done: true}, true
+		}
+		best, found = NextHop{Node: s, Successors: successors}, true
+	}
+	return best, found
+}
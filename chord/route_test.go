@@ -0,0 +1,103 @@
+package chord
+
+import (
+	"strconv"
+	"testing"
+)
+
+// route asks n for the owner of key and rel and the route to it, with ROUTE
+func route(t *testing.T, n *Node, key string, rel string) (ChordNode, []ChordNode) {
+	var g Get
+	if e := n.jrpc.ROUTE(&Operation{Params: DICT3Item{key, rel}, Routing: "iterative"}, &g); e != nil || g.Error != nil {
+		t.Fatal(key, e, g.Error)
+	}
+	return g.Result[0].(ChordNode), g.Result[1].([]ChordNode)
+}
+
+// checkRoute checks that a route found from n starts with n and asks no node twice
+func checkRoute(t *testing.T, n *Node, key string, r []ChordNode) {
+	if len(r) == 0 || r[0] != n.self {
+		t.Errorf("%s: the route %v does not start with the node asked", key, r)
+	}
+	seen := make(map[ChordNode]bool)
+	for _, s := range r {
+		if seen[s] {
+			t.Errorf("%s: the route %v asks %s:%d twice", key, r, s.IpAddress, s.Port)
+		}
+		seen[s] = true
+	}
+}
+
+// Iterative lookups find each triplet's owner, and the data handlers that route
+// by them store and read it there
+func TestIterativeRouting(t *testing.T) {
+	nodes := startRing(t, 6)
+	n := nodes[0]
+	for i := 0; i < 20; i++ {
+		key := "k" + strconv.Itoa(i)
+		o, r := route(t, n, key, "r")
+		if want := owner(nodes, key, "r"); o != want.self {
+			t.Errorf("%s: routed to %s:%d, not %s:%d", key, o.IpAddress, o.Port, want.self.IpAddress, want.self.Port)
+		}
+		checkRoute(t, n, key, r)
+
+		var g Get
+		d := &Operation{Params: DICT3Item{key, "r", map[string]interface{}{"content": key, "permission": "RW"}}, Routing: "iterative"}
+		if e := n.jrpc.INSERT(d, &g); e != nil || g.Error != nil {
+			t.Fatal(e, g.Error)
+		}
+		if c, ok := stored(owner(nodes, key, "r"), key, "r"); !ok || c != key {
+			t.Errorf("%s: the owner holds %q, %v", key, c, ok)
+		}
+		var l Get
+		if e := n.jrpc.LOOKUP(&Operation{Params: DICT3Item{key, "r"}, Routing: "iterative"}, &l); e != nil || len(l.Result) != 1 {
+			t.Errorf("%s: lookup gave %v %v", key, e, l.Error)
+		}
+	}
+}
+
+// An iterative lookup whose route passes through a node that has stopped goes
+// round it, from the successor list of the hop that pointed to it, to the owner
+func TestIterativeRoutingAroundStoppedNode(t *testing.T) {
+	nodes := startRing(t, 8)
+	n := nodes[0]
+
+	//the node met most often on the way to keys it does not own
+	type lookup struct {
+		key   string
+		owner *Node
+	}
+	through := make(map[*Node][]lookup)
+	at := make(map[ChordNode]*Node)
+	for _, x := range nodes {
+		at[x.self] = x
+	}
+	for i := 0; i < 200; i++ {
+		key := "k" + strconv.Itoa(i)
+		o := owner(nodes, key, "r")
+		_, r := route(t, n, key, "r")
+		for _, s := range r[1:] {
+			if at[s] != o {
+				through[at[s]] = append(through[at[s]], lookup{key, o})
+			}
+		}
+	}
+	var victim *Node
+	for x, l := range through {
+		if victim == nil || len(l) > len(through[victim]) {
+			victim = x
+		}
+	}
+	if victim == nil {
+		t.Fatal("no lookup went through another node")
+	}
+
+	victim.stop()
+	for _, l := range through[victim] {
+		o, r := route(t, n, l.key, "r")
+		if o != l.owner.self {
+			t.Errorf("%s: routed to %s:%d, not %s:%d", l.key, o.IpAddress, o.Port, l.owner.self.IpAddress, l.owner.self.Port)
+		}
+		checkRoute(t, n, l.key, r)
+	}
+}
@@ -0,0 +1,320 @@
+package chord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+/*
+Router carries data operations to the node that owns their triplet. A node owns
+the IDs in (predecessor, self]: all of the ring while it is alone, and while it
+knows of no predecessor but has other nodes, whatever lookups find it owns. An
+operation for an ID the node owns is applied where it is; any other is passed
+on, with the routing it asks for, to the owner found for the ID, counting one hop
+each time. A lookup whose owner does not answer is read from the owner's
+replicas instead. Every handler that reads or writes a triplet goes through here,
+so ownership, forwarding and retries are decided the same way for all of them;
+so do the walks round the ring of operations that every node has a part in.
+*/
+type Router struct {
+	node *Node
+}
+
+// Owns reports whether id falls in the range of the ring this node holds
+func (rt *Router) Owns(id ID) bool {
+	self, succ, pred := rt.node.neighbours()
+	return holds(id, self, succ, pred)
+}
+
+// holds reports whether id is in the range of the ring that self holds, given
+// its successor and predecessor: (pred, self], all of the ring when self is
+// alone, and only self's own ID while the predecessor is unknown but others are
+// not. Whether to apply an operation here and whether a lookup ends here are
+// both decided by this.
+func holds(id ID, self ChordNode, succ ChordNode, pred ChordNode) bool {
+	if pred == self {
+		return succ == self || id == self.NodeID
+	}
+	return id.In(pred.NodeID, self.NodeID)
+}
+
+// Range returns the range of the ring this node holds, (lo, hi]; lo == hi while
+// the predecessor is unknown, when the node holds the whole ring if it is alone
+// and otherwise nothing it can be sure of
+func (rt *Router) Range() (lo ID, hi ID) {
+	self, _, pred := rt.node.neighbours()
+	return pred.NodeID, self.NodeID
+}
+
+// RouteTo finds the owner of id, with the routing given or, if none is, the
+// routing of the config file
+func (rt *Router) RouteTo(ctx context.Context, routing string, id ID) (ChordNode, error) {
+	n := rt.node
+	if rt.Owns(id) {
+		return n.self, nil
+	}
+	var owner ChordNode
+	if e := n.lookup(ctx, routing, &ChordNode{NodeID: id}, &owner); e != nil {
+		return ChordNode{}, e
+	}
+	return owner, nil
+}
+
+// Apply applies the client operation d, on the triplet at id, with local when
+// this node owns id, and otherwise by calling method on the owner. An owner that
+// cannot be found or reached is reported to the client in g.
+func (rt *Router) Apply(ctx context.Context, id ID, method string, d *Operation, g *Get, local func(*Operation, *Get) error) error {
+	if rt.Owns(id) {
+		return local(d, g)
+	}
+	owner, e := rt.route(ctx, id, method, d)
+	if e == nil && owner == rt.node.self {
+		//our predecessor is gone, and its range with it
+		return local(d, g)
+	}
+	if e == nil {
+		e = rt.pass(ctx, owner, method, d, g)
+	}
+	return report(g, owner, e)
+}
+
+// Read appends the triplet d names, at id, to o: from this node if it owns id,
+// and otherwise from the owner, or the owner's replicas when it does not answer.
+// The owner is zero when none was found.
+func (rt *Router) Read(ctx context.Context, id ID, d *Operation, o *Dict3) (ChordNode, error) {
+	n := rt.node
+	if rt.Owns(id) {
+		return n.self, n.readOwn(d, o)
+	}
+	owner, e := rt.route(ctx, id, "JRPC.LOOKUP_DATA", d)
+	if e != nil {
+		return owner, e
+	}
+	if owner == n.self {
+		//our predecessor is gone and we have not taken over its range yet;
+		//calling ourselves would only route the lookup back here
+		return owner, n.jrpc.LOOKUP_REPLICA(d, o)
+	}
+	e = rt.pass(ctx, owner, "JRPC.LOOKUP_DATA", d, o)
+	if unreachable(e) {
+		//the owner is down; read one of its replicas instead
+		e = rt.readReplica(ctx, owner, d, o)
+	}
+	return owner, e
+}
+
+// readReplica looks d up on the successors of owner, which did not answer. The
+// first live node after owner holds a replica, or has already taken it over.
+func (rt *Router) readReplica(ctx context.Context, owner ChordNode, d *Operation, reply interface{}) error {
+	n := rt.node
+	last := owner
+	return rt.try(ctx, n.replicaCount(), "JRPC.LOOKUP_REPLICA", d, reply, func() (ChordNode, error) {
+		next := ChordNode{NodeID: last.NodeID.Add(IDFromUint64(1), n.bits)}
+		e := n.lookup(ctx, d.Routing, &next, &last)
+		return last, e
+	})
+}
+
+// route finds the owner of id for d, which is to be passed on with method,
+// unless d has been passed on so often that it must be going round in circles:
+// no route needs more hops than the ring has bits.
+func (rt *Router) route(ctx context.Context, id ID, method string, d *Operation) (ChordNode, error) {
+	if d.Hops >= rt.node.bits {
+		return ChordNode{}, fmt.Errorf("%s: passed on %d times without reaching the owner", method, d.Hops)
+	}
+	return rt.RouteTo(ctx, d.Routing, id)
+}
+
+// pass calls method on owner with d, one hop further on
+func (rt *Router) pass(ctx context.Context, owner ChordNode, method string, d *Operation, reply interface{}) error {
+	passed := *d
+	passed.Hops++
+	return rt.node.call(ctx, owner, method, &passed, reply)
+}
+
+// errNoLiveNode is returned by try when there is no node left to call
+var errNoLiveNode = errors.New("no live node left to call")
+
+// try calls method on the node next picks and, each time that node cannot be
+// reached, on the one next picks after it, up to limit nodes in all. The call
+// marks a node that fails as suspect, so routing steers around it from then on.
+// next returns the zero node when there is none left.
+func (rt *Router) try(ctx context.Context, limit int, method string, args interface{}, reply interface{}, next func() (ChordNode, error)) error {
+	for i := 0; i < limit; i++ {
+		node, e := next()
+		if e != nil {
+			return e
+		}
+		if node == (ChordNode{}) {
+			return errNoLiveNode
+		}
+		if e := rt.node.call(ctx, node, method, args, reply); !unreachable(e) || ctxErr(ctx) != nil {
+			return e
+		}
+	}
+	return fmt.Errorf("%s: none of %d nodes answered", method, limit)
+}
+
+// report tells the client in g that its operation did not get through to the
+// owner of its triplet, when e says so, and returns any other error. A zero owner
+// is one that was never found.
+func report(g *Get, owner ChordNode, e error) error {
+	if e == nil {
+		return nil
+	}
+	if owner == (ChordNode{}) {
+		g.fail(unreachableCode(e), e.Error(), nil)
+		return nil
+	}
+	if unreachable(e) || timedOut(e) {
+		g.fail(unreachableCode(e), e.Error(), owner)
+		return nil
+	}
+	return e
+}
+
+// readOwn appends the triplet d names to o, if this node holds it
+func (n *Node) readOwn(d *Operation, o *Dict3) error {
+	var g Get
+	key, rel, ok := d.keyRel(&g)
+	if !ok {
+		return g.Error
+	}
+
+	n.dataMu.Lock()
+	defer n.dataMu.Unlock()
+
+	t, found, e := n.get(key, rel)
+	if found {
+		n.touch(&t)
+		*o = append(*o, t)
+	}
+	return e
+}
+
+// Redirect is called by the handler of method, which d was passed to, before it
+// applies d at id. If this node no longer owns id, the ring has changed since d
+// was routed here, and d is passed on again to the owner; moved reports whether
+// it was, in which case the handler is done.
+func (rt *Router) Redirect(ctx context.Context, id ID, method string, d *Operation, g *Get) (moved bool, e error) {
+	if rt.Owns(id) {
+		return false, nil
+	}
+	owner, e := rt.route(ctx, id, method, d)
+	if e == nil && owner == rt.node.self {
+		return false, nil
+	}
+	if e == nil {
+		e = rt.pass(ctx, owner, method, d, g)
+	}
+	return true, report(g, owner, e)
+}
+
+// Trace finds the owner of id iteratively, whatever the routing asked for, and
+// returns it with the nodes asked on the way, in order, starting with this one
+func (rt *Router) Trace(ctx context.Context, id ID) (ChordNode, []ChordNode, error) {
+	return rt.node.findSuccessorIterative(ctx, ChordNode{NodeID: id})
+}
+
+// Match asks each node that may hold a triplet matching the partial [key,
+// relation] of d for its matches, once, and hands them to visit. The triplets of
+// one key sit in a single range of the ring (see KRHash), and only the owners of
+// that range are asked; those of one relation can be anywhere.
+func (rt *Router) Match(ctx context.Context, d *Operation, visit func(node ChordNode, matches DICT3Item) error) error {
+	n := rt.node
+	var g Get
+	key, _, ok := d.keyRel(&g)
+	if !ok {
+		return g.Error
+	}
+	ask := func(S ChordNode) error {
+		var found Get
+		var e error
+		if S == n.self {
+			e = n.jrpc.LOOKUP_MATCH_DATA(d, &found)
+		} else {
+			e = n.call(ctx, S, "JRPC.LOOKUP_MATCH_DATA", d, &found)
+		}
+		if e != nil {
+			return e
+		}
+		return visit(S, found.Result)
+	}
+
+	if key != "" {
+		lo, hi := KRHash_KeyRange(n.hasher, key, n.bits)
+		return rt.WalkRange(ctx, d.Routing, lo, hi, ask)
+	}
+	if e := ask(n.self); e != nil {
+		return e
+	}
+	return rt.Walk(ctx, ask)
+}
+
+// Walk calls visit on every other node of the ring, in successor order. A node
+// that cannot be reached is skipped using the successor list of the last node
+// that answered. The walk stops when ctx is done.
+func (rt *Router) Walk(ctx context.Context, visit func(ChordNode) error) error {
+	n := rt.node
+	next := n.successorList()
+	seen := make(map[ChordNode]bool)
+
+	for len(next) > 0 {
+		S := next[0]
+		if S == n.self || seen[S] {
+			return nil
+		}
+
+		if e := visit(S); e != nil {
+			if !unreachable(e) || ctxErr(ctx) != nil {
+				return e
+			}
+			next = next[1:]
+			continue
+		}
+		seen[S] = true
+
+		var list []ChordNode
+		if e := n.call(ctx, S, "JRPC.GET_SUCCESSOR_LIST", n.self, &list); e != nil {
+			if !unreachable(e) || ctxErr(ctx) != nil {
+				return e
+			}
+			next = next[1:]
+			continue
+		}
+		next = list
+	}
+	return errors.New("ring walk: no live successor left")
+}
+
+// WalkRange calls visit on every node that owns part of the ID range [lo, hi],
+// in successor order, finding each with the given routing. An owner that cannot
+// be reached is skipped; the node after it, visited next, has taken over its part
+// of the range.
+func (rt *Router) WalkRange(ctx context.Context, routing string, lo ID, hi ID, visit func(ChordNode) error) error {
+	n := rt.node
+	var S ChordNode
+	if e := n.lookup(ctx, routing, &ChordNode{NodeID: lo}, &S); e != nil {
+		return e
+	}
+	width := hi.Sub(lo, n.bits)
+	seen := make(map[ChordNode]bool)
+
+	for !seen[S] {
+		seen[S] = true
+		e := visit(S)
+		if e != nil && (!unreachable(e) || ctxErr(ctx) != nil) {
+			return e
+		}
+		//S is the last owner when the range ends at or before it
+		if e == nil && width.Cmp(S.NodeID.Sub(lo, n.bits)) <= 0 {
+			return nil
+		}
+		after := ChordNode{NodeID: S.NodeID.Add(IDFromUint64(1), n.bits)}
+		if e := n.lookup(ctx, routing, &after, &S); e != nil {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package chord
+
+import (
+	"errors"
+	"log"
+	"os"
+	"time"
+)
+
+/*
+A Store keeps the triplets a node owns. Every triplet is filed under its position
+on the ring, the ID of its compound key, so that the triplets of one part of the
+ring can be read without the rest. Stores are not safe for concurrent use; the
+node only calls them holding dataMu.
+*/
+type Store interface {
+	// Get returns the triplet [key, rel], and whether there is one
+	Get(key string, rel string) (Triplet, bool, error)
+
+	// Put stores t, in place of the triplet with the same key and relation if
+	// there is one. The write is durable when Put returns
+	Put(t Triplet) error
+
+	// Delete removes the triplet [key, rel], if there is one
+	Delete(key string, rel string) error
+
+	// Scan calls visit with every triplet whose position is in the interval
+	// (lo, hi] of the ring, the whole ring when lo == hi, in no particular order,
+	// until visit returns false. visit must not change the store
+	Scan(lo ID, hi ID, visit func(Triplet) bool) error
+
+	// Snapshot writes the whole store out in compact form; done after changes in bulk
+	Snapshot() error
+
+	// Close snapshots the store and releases its files
+	Close() error
+}
+
+// corruptError is returned when opening a store whose files cannot be read back
+type corruptError struct {
+	path  string   //the file that cannot be read
+	files []string //all files of the store
+	err   error
+}
+
+func (e *corruptError) Error() string {
+	return e.path + " is corrupt: " + e.err.Error()
+}
+
+// quarantine moves the files of a corrupt store aside, keeping them for a look
+// later; the store is then created again, empty
+func (e *corruptError) quarantine() error {
+	suffix := ".corrupt." + time.Now().UTC().Format("20060102T150405Z")
+	for _, f := range e.files {
+		if _, err := os.Stat(f); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.Rename(f, f+suffix); err != nil {
+			return err
+		}
+		log.Printf("moved %s aside to %s", f, f+suffix)
+	}
+	return nil
+}
+
+// openStore opens the store described in the config file, creating it if it
+// does not exist. pos gives the ring position of a compound key.
+func openStore(c PersistentStorageContainerType, pos func(key string, rel string) ID) (Store, error) {
+	switch c.Type {
+	case "memory":
+		return newMemStore(pos), nil
+	case "", "json":
+		return openFileStore(c.File, pos)
+	case "kv":
+		return openKVStore(c.File, pos)
+	}
+	return nil, errors.New("unknown persistentStorageContainer type: " + c.Type)
+}
+
+// memStore keeps the triplets in memory only; they are gone once the node stops
+type memStore struct {
+	pos func(key string, rel string) ID
+
+	dict3 Dict3
+	ids   []ID           //ring position of each triplet in dict3
+	at    map[keyRel]int //where each triplet is in dict3
+}
+
+func newMemStore(pos func(key string, rel string) ID) *memStore {
+	return &memStore{pos: pos, at: make(map[keyRel]int)}
+}
+
+func (s *memStore) Get(key string, rel string) (Triplet, bool, error) {
+	if i, ok := s.at[keyRel{key, rel}]; ok {
+		return s.dict3[i], true, nil
+	}
+	return Triplet{}, false, nil
+}
+
+func (s *memStore) Put(t Triplet) error {
+	if i, ok := s.at[keyRel{t.Key, t.Relation}]; ok {
+		s.dict3[i] = t
+		return nil
+	}
+	s.at[keyRel{t.Key, t.Relation}] = len(s.dict3)
+	s.dict3 = append(s.dict3, t)
+	s.ids = append(s.ids, s.pos(t.Key, t.Relation))
+	return nil
+}
+
+// Delete moves the last triplet into the place of the one removed
+func (s *memStore) Delete(key string, rel string) error {
+	i, ok := s.at[keyRel{key, rel}]
+	if !ok {
+		return nil
+	}
+	delete(s.at, keyRel{key, rel})
+	last := len(s.dict3) - 1
+	if i != last {
+		s.dict3[i], s.ids[i] = s.dict3[last], s.ids[last]
+		s.at[keyRel{s.dict3[i].Key, s.dict3[i].Relation}] = i
+	}
+	s.dict3, s.ids = s.dict3[:last], s.ids[:last]
+	return nil
+}
+
+func (s *memStore) Scan(lo ID, hi ID, visit func(Triplet) bool) error {
+	for i := range s.dict3 {
+		if s.ids[i].In(lo, hi) && !visit(s.dict3[i]) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *memStore) Snapshot() error {
+	return nil
+}
+
+func (s *memStore) Close() error {
+	return nil
+}
@@ -0,0 +1,232 @@
+package chord
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// tempPath returns the path of a file, not yet created, in a directory removed
+// when the test ends
+func tempPath(t *testing.T, name string) string {
+	dir, e := ioutil.TempDir("", "chord")
+	if e != nil {
+		t.Fatal(e)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return filepath.Join(dir, name)
+}
+
+func noPos(key string, rel string) ID { return ID{} }
+
+func fileSize(t *testing.T, path string) int64 {
+	info, e := os.Stat(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return info.Size()
+}
+
+// A record cut short by a crash is dropped, and the store opens and takes writes
+func TestKVStoreTornRecord(t *testing.T) {
+	path := tempPath(t, "kv")
+	s, e := openKVStore(path, noPos)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for _, k := range []string{"a", "b"} {
+		if e := s.Put(Triplet{Key: k, Relation: "r", Value: valuetype{Content: k, Permission: "RW"}}); e != nil {
+			t.Fatal(e)
+		}
+	}
+	s.file.Close()
+	good := fileSize(t, path)
+	f, e := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if e == nil {
+		_, e = f.WriteString(`{"op": "put", "triplet": ["c", "r", {"cont`)
+		f.Close()
+	}
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if s, e = openKVStore(path, noPos); e != nil {
+		t.Fatal(e)
+	}
+	defer s.Close()
+	if size := fileSize(t, path); size != good {
+		t.Errorf("the file is %d bytes after the torn record is dropped, not %d", size, good)
+	}
+	if e := s.Put(Triplet{Key: "c", Relation: "r", Value: valuetype{Content: "c", Permission: "RW"}}); e != nil {
+		t.Fatal(e)
+	}
+	for _, k := range []string{"a", "b", "c"} {
+		if x, ok, e := s.Get(k, "r"); e != nil || !ok || x.Value.Content != k {
+			t.Errorf("%s: %+v %v %v", k, x, ok, e)
+		}
+	}
+}
+
+// After a crash the log is replayed on top of the snapshot, less a last record cut
+// short, and compacted into a new snapshot
+func TestFileStoreReplaysLog(t *testing.T) {
+	path := tempPath(t, "data.json")
+	s, e := openFileStore(path, noPos)
+	if e != nil {
+		t.Fatal(e)
+	}
+	put := func(k string, content string) {
+		if e := s.Put(Triplet{Key: k, Relation: "r", Value: valuetype{Content: content, Permission: "RW"}}); e != nil {
+			t.Fatal(e)
+		}
+	}
+	put("a", "a")
+	put("b", "b")
+	if e := s.Snapshot(); e != nil {
+		t.Fatal(e)
+	}
+	put("a", "x")
+	put("c", "c")
+	if e := s.Delete("b", "r"); e != nil {
+		t.Fatal(e)
+	}
+	//crash: the log is left as it is, with no snapshot of the writes in it
+	s.wal.Close()
+	if fileSize(t, path+".wal") == 0 {
+		t.Fatal("the writes after the snapshot are not in the log")
+	}
+	f, e := os.OpenFile(path+".wal", os.O_WRONLY|os.O_APPEND, 0)
+	if e == nil {
+		_, e = f.WriteString(`{"op": "put", "triplet": ["d", "r", {"cont`)
+		f.Close()
+	}
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	check := func(when string) {
+		for k, want := range map[string]string{"a": "x", "b": "", "c": "c", "d": ""} {
+			x, ok, _ := s.Get(k, "r")
+			if ok != (want != "") || ok && x.Value.Content != want {
+				t.Errorf("%s, %s: %+v %v, want %q", when, k, x, ok, want)
+			}
+		}
+	}
+	if s, e = openFileStore(path, noPos); e != nil {
+		t.Fatal(e)
+	}
+	check("replayed")
+	if size := fileSize(t, path+".wal"); size != 0 {
+		t.Errorf("the log is %d bytes after it is replayed, not empty", size)
+	}
+	s.wal.Close()
+
+	//the replayed writes are in the snapshot taken on opening
+	if s, e = openFileStore(path, noPos); e != nil {
+		t.Fatal(e)
+	}
+	defer s.Close()
+	check("reopened")
+}
+
+// A few dead records wait for Close; they are not compacted at every Snapshot
+func TestKVStoreSnapshotThreshold(t *testing.T) {
+	path := tempPath(t, "kv")
+	s, e := openKVStore(path, noPos)
+	if e != nil {
+		t.Fatal(e)
+	}
+	for i := 0; i < 10; i++ {
+		if e := s.Put(Triplet{Key: "k", Relation: "r", Value: valuetype{Content: strconv.Itoa(i), Permission: "RW"}}); e != nil {
+			t.Fatal(e)
+		}
+	}
+	before := fileSize(t, path)
+	if e := s.Snapshot(); e != nil {
+		t.Fatal(e)
+	}
+	if s.dead != 9 || fileSize(t, path) != before {
+		t.Errorf("Snapshot compacted %d dead records", 9-s.dead)
+	}
+	if e := s.Close(); e != nil {
+		t.Fatal(e)
+	}
+	if size := fileSize(t, path); size >= before/5 {
+		t.Errorf("Close left %d of %d bytes", size, before)
+	}
+
+	if s, e = openKVStore(path, noPos); e != nil {
+		t.Fatal(e)
+	}
+	defer s.Close()
+	if x, ok, e := s.Get("k", "r"); e != nil || !ok || x.Value.Content != "9" {
+		t.Errorf("after compaction: %+v %v %v", x, ok, e)
+	}
+}
+
+// A triplet that is JSON but not a valid value costs only itself; a file that is
+// not JSON is still corrupt
+func TestFileStoreSkipsInvalidTriplets(t *testing.T) {
+	path := tempPath(t, "data.json")
+	snapshot := `[["a", "r", {"content": "a", "permission": "RW"}],
+		["b", "r", {"content": "b", "permission": "rw"}],
+		["c", "r", {"content": "c", "permission": "R", "created": "someday"}],
+		["d", "r", {"content": "d", "permission": "R"}]]`
+	wal := `{"op": "put", "triplet": ["e", "r", {"content": "e", "permission": "RW"}]}
+{"op": "put", "triplet": ["a", "r", {"content": "x", "permission": "W"}]}
+{"op": "put", "triplet": ["f", "r", {"content": "f", "permission": "R"}]}
+`
+	if e := ioutil.WriteFile(path, []byte(snapshot), 0664); e != nil {
+		t.Fatal(e)
+	}
+	if e := ioutil.WriteFile(path+".wal", []byte(wal), 0664); e != nil {
+		t.Fatal(e)
+	}
+	s, e := openFileStore(path, noPos)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer s.Close()
+	for k, want := range map[string]string{"a": "a", "b": "", "c": "", "d": "d", "e": "e", "f": "f"} {
+		x, ok, _ := s.Get(k, "r")
+		if ok != (want != "") || ok && x.Value.Content != want {
+			t.Errorf("%s: %+v %v, want %q", k, x, ok, want)
+		}
+	}
+
+	path = tempPath(t, "data.json")
+	ioutil.WriteFile(path, []byte(`[["a", "r", {"content": "a", "permission": "RW"}],`), 0664)
+	if _, e := openFileStore(path, noPos); e == nil {
+		t.Error("a snapshot cut short opens")
+	} else if _, ok := e.(*corruptError); !ok {
+		t.Errorf("a snapshot cut short: %v, not corrupt", e)
+	}
+}
+
+func TestKVStoreSkipsInvalidTriplets(t *testing.T) {
+	path := tempPath(t, "kv")
+	data := `{"op": "put", "triplet": ["a", "r", {"content": "a", "permission": "RW"}]}
+{"op": "put", "triplet": ["a", "r", {"content": "x", "permission": "rw"}]}
+{"op": "put", "triplet": ["b", "r", {"content": "b", "permission": "R", "modified": "soon"}]}
+{"op": "put", "triplet": ["c", "r", {"content": "c", "permission": "R"}]}
+`
+	if e := ioutil.WriteFile(path, []byte(data), 0664); e != nil {
+		t.Fatal(e)
+	}
+	s, e := openKVStore(path, noPos)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer s.Close()
+	for k, want := range map[string]string{"a": "a", "b": "", "c": "c"} {
+		x, ok, e := s.Get(k, "r")
+		if e != nil || ok != (want != "") || ok && x.Value.Content != want {
+			t.Errorf("%s: %+v %v %v, want %q", k, x, ok, e, want)
+		}
+	}
+	if s.dead != 2 {
+		t.Errorf("%d dead records, not 2", s.dead)
+	}
+}
@@ -0,0 +1,210 @@
+package chord
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Layout of the timestamps in dict3 files and requests from before they were RFC3339
+const legacyTime = "1/2/2006, 15:04:05"
+
+// value in triplets. Size and the timestamps are kept by the server: Size is
+// the length of Content in bytes, and the times are set when the triplet is
+// written (see stamp) and read.
+type valuetype struct {
+	Content    string
+	Size       int
+	Created    time.Time
+	Modified   time.Time
+	Accessed   time.Time
+	Permission string
+}
+
+// a value as it is written in JSON
+type valueJSON struct {
+	Content    string      `json:"content"`
+	Size       interface{} `json:"size"`
+	Created    string      `json:"created"`
+	Modified   string      `json:"modified"`
+	Accessed   string      `json:"accessed"`
+	Permission string      `json:"permission"`
+}
+
+func (v valuetype) MarshalJSON() ([]byte, error) {
+	return json.Marshal(valueJSON{
+		Content:    v.Content,
+		Size:       v.Size,
+		Created:    formatTime(v.Created),
+		Modified:   formatTime(v.Modified),
+		Accessed:   formatTime(v.Accessed),
+		Permission: v.Permission,
+	})
+}
+
+// UnmarshalJSON reads timestamps in RFC3339 or in the legacy layout. Whatever
+// size is given is ignored and computed again from the content.
+func (v *valuetype) UnmarshalJSON(data []byte) error {
+	var x valueJSON
+	if e := json.Unmarshal(data, &x); e != nil {
+		return e
+	}
+	var e1, e2, e3 error
+	v.Content = x.Content
+	v.Size = len(x.Content)
+	v.Created, e1 = parseTime(x.Created)
+	v.Modified, e2 = parseTime(x.Modified)
+	v.Accessed, e3 = parseTime(x.Accessed)
+	v.Permission = x.Permission
+	for _, e := range []error{e1, e2, e3} {
+		if e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// parseTime reads an RFC3339 or legacy timestamp; an empty one is the zero time
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, e := time.Parse(time.RFC3339, s); e == nil {
+		return t, nil
+	}
+	t, e := time.Parse(legacyTime, s)
+	if e != nil {
+		return time.Time{}, fmt.Errorf("timestamp %q is neither RFC3339 nor %q", s, legacyTime)
+	}
+	return t, nil
+}
+
+// stamp fills in the server kept fields of t, which is being written now in
+// place of old (nil for a new triplet)
+func (t *Triplet) stamp(old *Triplet) {
+	now := time.Now().UTC().Truncate(time.Second)
+	t.Value.Size = len(t.Value.Content)
+	t.Value.Created = now
+	if old != nil {
+		t.Value.Created = old.Value.Created
+	}
+	t.Value.Modified = now
+	t.Value.Accessed = now
+}
+
+// Triplet is a DICT3 entry: a value stored under a key and a relation. On the
+// wire and on disk it is the array [key, relation, value].
+type Triplet struct {
+	Key      string
+	Relation string
+	Value    valuetype
+}
+
+func (t Triplet) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{t.Key, t.Relation, t.Value})
+}
+
+// UnmarshalJSON reads a triplet from a file or a peer, timestamps and all
+func (t *Triplet) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	return t.unmarshal(data, true)
+}
+
+// unmarshal reads [key, relation, value]; of the value only the content and
+// permission are read unless stamped, since the rest is the server's to set
+func (t *Triplet) unmarshal(data []byte, stamped bool) error {
+	var raw []json.RawMessage
+	if e := json.Unmarshal(data, &raw); e != nil || len(raw) != 3 {
+		return errors.New("a triplet is [key, relation, value]")
+	}
+	var x Triplet
+	if json.Unmarshal(raw[0], &x.Key) != nil || json.Unmarshal(raw[1], &x.Relation) != nil {
+		return errors.New("key and relation must be strings")
+	}
+	if x.Key == "" || x.Relation == "" {
+		return errors.New("key and relation must not be empty")
+	}
+	if stamped {
+		if e := json.Unmarshal(raw[2], &x.Value); e != nil {
+			return errors.New("value: " + e.Error())
+		}
+	} else {
+		var v struct {
+			Content    string `json:"content"`
+			Permission string `json:"permission"`
+		}
+		if e := json.Unmarshal(raw[2], &v); e != nil {
+			return errors.New("value: " + e.Error())
+		}
+		x.Value.Content, x.Value.Size, x.Value.Permission = v.Content, len(v.Content), v.Permission
+	}
+	if e := x.Value.validate(); e != nil {
+		return e
+	}
+	*t = x
+	return nil
+}
+
+// the rules a value must follow to be stored
+func (v *valuetype) validate() error {
+	if v.Permission != "R" && v.Permission != "RW" {
+		return errors.New(`value: permission must be "R" or "RW"`)
+	}
+	return nil
+}
+
+// writable is the access check for every change to a stored triplet, be it an
+// update, a delete or a purge: only "RW" triplets may change
+func (t *Triplet) writable() bool {
+	return t.Value.Permission == "RW"
+}
+
+// checkWrite reports PermissionDenied in g unless stored is writable
+func checkWrite(stored Triplet, g *Get) bool {
+	if stored.writable() {
+		return true
+	}
+	g.fail(PermissionDenied, "triplet is read-only", []string{stored.Key, stored.Relation})
+	return false
+}
+
+// keyRel reads the [key, relation] params of a lookup or delete; either may be
+// empty. A bad request is reported in g and ok is false.
+func (d *Operation) keyRel(g *Get) (key string, rel string, ok bool) {
+	if len(d.Params) < 2 {
+		g.fail(BadParams, "params are [key, relation]", nil)
+		return "", "", false
+	}
+	key, ok1 := d.Params[0].(string)
+	rel, ok2 := d.Params[1].(string)
+	if !ok1 || !ok2 {
+		g.fail(BadParams, "key and relation must be strings", nil)
+		return "", "", false
+	}
+	return key, rel, true
+}
+
+// triplet reads the [key, relation, value] params of an insert; any size or
+// timestamps the client sends are ignored. A bad request is reported in g and ok
+// is false.
+func (d *Operation) triplet(g *Get) (t Triplet, ok bool) {
+	data, e := json.Marshal(d.Params)
+	if e == nil {
+		e = t.unmarshal(data, false)
+	}
+	if e != nil {
+		g.fail(BadParams, e.Error(), nil)
+		return t, false
+	}
+	return t, true
+}
@@ -0,0 +1,45 @@
+package chord
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// A client's size and timestamps are the server's to set, and never rejected
+func TestClientTripletIgnoresTimestamps(t *testing.T) {
+	var g Get
+	d := &Operation{Params: DICT3Item{"k", "r", map[string]interface{}{
+		"content": "abc", "size": "big", "permission": "RW",
+		"created": "yesterday", "modified": 5, "accessed": "1/02/2006, 15:04:05",
+	}}}
+	x, ok := d.triplet(&g)
+	if !ok {
+		t.Fatalf("insert rejected: %v", g.Error)
+	}
+	if x.Value.Content != "abc" || x.Value.Size != 3 || x.Value.Permission != "RW" {
+		t.Errorf("got %+v", x.Value)
+	}
+	if !x.Value.Created.IsZero() || !x.Value.Modified.IsZero() || !x.Value.Accessed.IsZero() {
+		t.Errorf("client timestamps were kept: %+v", x.Value)
+	}
+
+	d.Params[2] = map[string]interface{}{"content": "abc", "permission": "rw"}
+	if _, ok := d.triplet(&g); ok || g.Error.Code != BadParams {
+		t.Errorf("bad permission accepted: %v", g.Error)
+	}
+}
+
+// Files and peers keep their timestamps, in RFC3339 or the legacy layout
+func TestStoredTripletKeepsTimestamps(t *testing.T) {
+	var x Triplet
+	data := `["k", "r", {"content": "abc", "permission": "R", "created": "2015-03-01T10:00:00Z", "modified": "3/1/2015, 11:00:00"}]`
+	if e := json.Unmarshal([]byte(data), &x); e != nil {
+		t.Fatal(e)
+	}
+	if x.Value.Created.Hour() != 10 || x.Value.Modified.Hour() != 11 || !x.Value.Accessed.IsZero() {
+		t.Errorf("got %+v", x.Value)
+	}
+	if e := json.Unmarshal([]byte(`["k", "r", {"permission": "R", "created": "yesterday"}]`), &x); e == nil {
+		t.Error("a bad stored timestamp is accepted")
+	}
+}
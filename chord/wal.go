@@ -0,0 +1,263 @@
+package chord
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+/*
+fileStore is the "json" store: the triplets in memory, as a snapshot in the file
+named in the config, and a write-ahead log next to it (the same name with ".wal"
+added). A single write is appended to the log and synced before it is applied;
+changes in bulk, and a log that has grown past walCompactSize records, are
+compacted into a new snapshot, which is written to a temporary file and renamed
+over the old one. At startup the log is replayed on top of the snapshot.
+*/
+type fileStore struct {
+	*memStore
+
+	path       string
+	wal        *os.File
+	walRecords int //records in the log
+}
+
+// log records after which the next write compacts the log into a snapshot
+const walCompactSize = 1000
+
+// One write in the log: Triplet stored by "put", or Key and Rel removed by "delete"
+type walRecord struct {
+	Op      string   `json:"op"`
+	Triplet *Triplet `json:"triplet,omitempty"`
+	Key     string   `json:"key,omitempty"`
+	Rel     string   `json:"rel,omitempty"`
+}
+
+// openFileStore loads the snapshot at path, replays the log on top of it and
+// opens the log for writing. A missing snapshot is created empty. A file that is
+// not JSON is corrupt; a triplet in it that is, but breaks the rules of a stored
+// value, is logged and skipped.
+func openFileStore(path string, pos func(key string, rel string) ID) (*fileStore, error) {
+	s := &fileStore{memStore: newMemStore(pos), path: path}
+
+	file, e := ioutil.ReadFile(path)
+	if os.IsNotExist(e) {
+		log.Printf("%s does not exist; starting with an empty store", path)
+		file, e = nil, s.Snapshot()
+	}
+	if e != nil {
+		return nil, e
+	}
+	if len(file) > 0 {
+		var dict3 []json.RawMessage
+		if e = json.Unmarshal(file, &dict3); e != nil {
+			return nil, s.corrupt(path, e)
+		}
+		for i, raw := range dict3 {
+			var t Triplet
+			if e := json.Unmarshal(raw, &t); e != nil {
+				log.Printf("%s: skipping triplet %d, %s: %v", path, i, raw, e)
+				continue
+			}
+			s.memStore.Put(t)
+		}
+	}
+
+	replayed, e := s.replay()
+	if e != nil {
+		return nil, e
+	}
+	s.wal, e = os.OpenFile(s.walPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0664)
+	if e != nil {
+		return nil, e
+	}
+	if replayed > 0 {
+		log.Printf("replayed %d writes from %s", replayed, s.walPath())
+	}
+	if info, e := s.wal.Stat(); e == nil && info.Size() > 0 {
+		//start over with an empty log, without any torn record at its end
+		if e = s.Snapshot(); e != nil {
+			s.wal.Close()
+			return nil, e
+		}
+	}
+	return s, nil
+}
+
+func (s *fileStore) walPath() string {
+	return s.path + ".wal"
+}
+
+func (s *fileStore) corrupt(path string, e error) error {
+	return &corruptError{path: path, files: []string{s.path, s.walPath()}, err: e}
+}
+
+// replay applies the records of the log. A last record cut short by a crash was
+// never acknowledged, and is dropped; a record whose triplet breaks the rules of a
+// stored value is logged and skipped.
+func (s *fileStore) replay() (int, error) {
+	data, e := ioutil.ReadFile(s.walPath())
+	if os.IsNotExist(e) {
+		return 0, nil
+	}
+	if e != nil {
+		return 0, e
+	}
+
+	lines := bytes.Split(data, []byte("\n"))
+	count := 0
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var rec walRecord
+		if e := json.Unmarshal(line, &rec); e != nil {
+			if json.Valid(line) {
+				log.Printf("%s: skipping record %d, %s: %v", s.walPath(), i, line, e)
+				continue
+			}
+			if i == len(lines)-1 {
+				log.Printf("%s: dropping a torn last record", s.walPath())
+				break
+			}
+			return count, s.corrupt(s.walPath(), e)
+		}
+		switch rec.Op {
+		case "put":
+			if rec.Triplet != nil {
+				s.memStore.Put(*rec.Triplet)
+			}
+		case "delete":
+			s.memStore.Delete(rec.Key, rec.Rel)
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (s *fileStore) Put(t Triplet) error {
+	if e := s.appendLog(walRecord{Op: "put", Triplet: &t}); e != nil {
+		return e
+	}
+	return s.memStore.Put(t)
+}
+
+func (s *fileStore) Delete(key string, rel string) error {
+	if _, ok := s.at[keyRel{key, rel}]; !ok {
+		return nil
+	}
+	if e := s.appendLog(walRecord{Op: "delete", Key: key, Rel: rel}); e != nil {
+		return e
+	}
+	return s.memStore.Delete(key, rel)
+}
+
+// appendLog writes rec to the log and waits for it to reach the disk
+func (s *fileStore) appendLog(rec walRecord) error {
+	if s.wal == nil {
+		return errors.New(s.path + ": store is closed")
+	}
+	if s.walRecords >= walCompactSize {
+		if e := s.Snapshot(); e != nil {
+			return e
+		}
+	}
+	line, e := json.Marshal(rec)
+	if e != nil {
+		return e
+	}
+	if _, e = s.wal.Write(append(line, '\n')); e == nil {
+		e = s.wal.Sync()
+	}
+	if e != nil {
+		//rec was not applied; a snapshot leaves no part of it in the log
+		s.Snapshot()
+		return e
+	}
+	s.walRecords++
+	return nil
+}
+
+// Snapshot writes all the triplets as the new snapshot and empties the log
+func (s *fileStore) Snapshot() error {
+	dict3 := s.dict3
+	if dict3 == nil {
+		dict3 = Dict3{}
+	}
+	file, e := json.Marshal(dict3)
+	if e != nil {
+		return e
+	}
+	if e = writeFile(s.path, file); e != nil {
+		return e
+	}
+
+	//every record in the log is in the snapshot now
+	if s.wal != nil {
+		if e = s.wal.Truncate(0); e != nil {
+			return e
+		}
+		if e = s.wal.Sync(); e != nil {
+			return e
+		}
+	}
+	s.walRecords = 0
+	return nil
+}
+
+func (s *fileStore) Close() error {
+	if s.wal == nil {
+		return nil
+	}
+	e := s.Snapshot()
+	if e2 := s.wal.Close(); e == nil {
+		e = e2
+	}
+	s.wal = nil
+	return e
+}
+
+// writeFile replaces the file at path with data: it is written and synced to a
+// temporary file in the same directory, which is then renamed over path, and the
+// directory synced so that the rename is on disk before writeFile returns
+func writeFile(path string, data []byte) error {
+	tmp, e := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if e != nil {
+		return e
+	}
+	if _, e = tmp.Write(data); e == nil {
+		e = tmp.Sync()
+	}
+	if e2 := tmp.Close(); e == nil {
+		e = e2
+	}
+	if e == nil {
+		e = os.Chmod(tmp.Name(), 0664)
+	}
+	if e == nil {
+		e = os.Rename(tmp.Name(), path)
+	}
+	if e != nil {
+		os.Remove(tmp.Name())
+		return e
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir waits for the entries of the directory dir to reach the disk. A file
+// renamed into dir may otherwise come back after a crash under its old name.
+func syncDir(dir string) error {
+	d, e := os.Open(dir)
+	if e != nil {
+		return e
+	}
+	e = d.Sync()
+	if e2 := d.Close(); e == nil {
+		e = e2
+	}
+	return e
+}
@@ -37,16 +37,30 @@ type Dict3 []DICT3Item
 type DICT3Item []interface{}
 
 type Operation struct {
-	Method string
-	Params DICT3Item
-	//Id     int
+	Method  string      `json:"method"`
+	Params  DICT3Item   `json:"params"`
+	Id      interface{} `json:"id"`
+	Routing string      `json:"routing,omitempty"`
 }
 type Get struct {
-	Result DICT3Item
-	//Id     int
-	Error  error
+	Result DICT3Item   `json:"result"`
+	Id     interface{} `json:"id"`
+	Error  *Error      `json:"error"`
 }
 
+//JSON-RPC 2.0 error object
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+//error codes, as the server gives them
+const (
+	InternalError = -32603
+	Timeout       = -32004
+)
+
 var NodeParamsType ConfigParamsType
 var dict3 DICT3Item //DICT3Item
 var re Get
@@ -70,8 +84,9 @@ func main() {
 
 
 		re.Result = nil
-		//re.Id = -1
+		re.Id = nil
 		re.Error = nil
+		op.Id = nil
 
 		fmt.Print("Please Enter a properly-structured JSON-RPC message: ")
 		text, e := reader.ReadString('\n')
@@ -108,13 +123,26 @@ func main() {
 		serverCall := client.Go("JRPC."+s, op, re2, nil)
 
 		serverCall = <-serverCall.Done
+		client.Close()
 		if serverCall.Error != nil {
-			log.Fatal("Server call returned an error: ", serverCall.Error.Error())
+			//the handler failed rather than answer with an error object: report it as
+			//the server does, in place of the result
+			re2.Error = &Error{Code: InternalError, Message: serverCall.Error.Error()}
+			if strings.HasSuffix(serverCall.Error.Error(), " timed out") {
+				re2.Error.Code = Timeout
+			}
+		}
+
+		//answer as JSON-RPC 2.0: the request id, and either result or error
+		response := map[string]interface{}{"jsonrpc": "2.0", "id": op.Id}
+		if re2.Error != nil {
+			response["error"] = re2.Error
+		} else {
+			response["result"] = re2.Result
 		}
-		client.Close()
 
-		if strings.EqualFold(strings.ToUpper(NodeParamsType.Methods[2]), s) != true && strings.EqualFold(strings.ToUpper(NodeParamsType.Methods[3]), s) != true && strings.EqualFold(strings.ToUpper(NodeParamsType.Methods[6]), s) != true {
-			file, _ = json.Marshal(re2)
+		if re2.Error != nil || strings.EqualFold(strings.ToUpper(NodeParamsType.Methods[2]), s) != true && strings.EqualFold(strings.ToUpper(NodeParamsType.Methods[3]), s) != true && strings.EqualFold(strings.ToUpper(NodeParamsType.Methods[6]), s) != true {
+			file, _ = json.Marshal(response)
 			fmt.Printf("Results: %v\n", string(file))
 			//fmt.Printf("Results: %v\n", response)
 
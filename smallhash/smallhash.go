@@ -5,7 +5,8 @@ import "strings"
 import "strconv"
 import "math/big"
 import "crypto/sha1"
-import "encoding/hex"
+import "crypto/sha256"
+import "errors"
 
 const OFFSET_BASIS uint64 = 2166136261
 const FNV_PRIME uint64 = 16777619
@@ -106,21 +107,48 @@ func LeftPad(value int, pad int, pLen int) string {
 	return strings.Repeat(padStr, pLen) + valueStr
 }
 
-// Should return an 8-bit hash, very naive lookup algorithm
+// Returns an 8-bit hash: the leading byte of the SHA-1 digest
 func Sha1ShortHash(keyword string) uint8 {
-	binary := big.NewInt(0)
+	return uint8(SHA1{}.Hash(keyword, 8).Uint64())
+}
+
+// A Hasher places a string on a ring of 2^bits positions: 0 <= Hash(keyword, bits) < 2^bits
+type Hasher interface {
+	Hash(keyword string, bits int) *big.Int
+}
 
-	// Convert to sha1 hash
-	hash := sha1.New()
-	hash.Write([]byte(keyword))
+// SHA-1 truncated to its leading bits (at most 160), as in consistent hashing
+type SHA1 struct{}
 
-	//Encode the 160-bit hash (base 16: hex) to string
-	hexstr := hex.EncodeToString(hash.Sum(nil))
+func (SHA1) Hash(keyword string, bits int) *big.Int {
+	sum := sha1.Sum([]byte(keyword))
+	return truncate(sum[:], bits)
+}
+
+// SHA-256 truncated to its leading bits (at most 256)
+type SHA256 struct{}
 
-	//Convert to regular integer
-	binary.SetString(hexstr, 16)
-	resultStr := binary.String()
-	//fmt.Println(resultStr)
-	resultInt, _ := strconv.Atoi(resultStr[0:2])
-	return uint8(resultInt)
+func (SHA256) Hash(keyword string, bits int) *big.Int {
+	sum := sha256.Sum256([]byte(keyword))
+	return truncate(sum[:], bits)
+}
+
+// keep the leading bits of a digest
+func truncate(sum []byte, bits int) *big.Int {
+	result := new(big.Int).SetBytes(sum)
+	if shift := 8*len(sum) - bits; shift > 0 {
+		result.Rsh(result, uint(shift))
+	}
+	return result
+}
+
+// New returns the hasher with the given name: "sha1" (also the default for "") or "sha256"
+func New(name string) (Hasher, error) {
+	switch strings.ToLower(name) {
+	case "", "sha1", "sha-1":
+		return SHA1{}, nil
+	case "sha256", "sha-256":
+		return SHA256{}, nil
+	}
+	return nil, errors.New("smallhash: unknown hash " + name)
 }
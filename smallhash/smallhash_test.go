@@ -0,0 +1,98 @@
+package smallhash
+
+import (
+	"math/big"
+	"strconv"
+	"testing"
+)
+
+// chiSquare hashes count keywords made by name into 2^bits buckets and returns
+// the chi-square statistic of the bucket sizes against a uniform spread
+func chiSquare(h Hasher, bits int, count int, name func(i int) string) float64 {
+	buckets := make([]int, 1<<uint(bits))
+	for i := 0; i < count; i++ {
+		buckets[h.Hash(name(i), bits).Int64()]++
+	}
+	expected := float64(count) / float64(len(buckets))
+	x2 := 0.0
+	for _, b := range buckets {
+		d := float64(b) - expected
+		x2 += d * d / expected
+	}
+	return x2
+}
+
+func TestHashBalance(t *testing.T) {
+	//99.9th percentile of chi-square with 63 degrees of freedom
+	const limit = 103.4
+	keys := func(i int) string { return "key" + strconv.Itoa(i) }
+	addresses := func(i int) string { return "127.0.0.1:" + strconv.Itoa(5000+i) }
+
+	for _, h := range []Hasher{SHA1{}, SHA256{}} {
+		x2 := chiSquare(h, 6, 64000, keys)
+		t.Logf("%T: keys over 64 buckets, chi-square %.1f", h, x2)
+		if x2 > limit {
+			t.Errorf("%T: keys spread unevenly over 64 buckets, chi-square %.1f", h, x2)
+		}
+		//nodes on nearby ports must not land on nearby IDs
+		x2 = chiSquare(h, 6, 6400, addresses)
+		t.Logf("%T: addresses over 64 buckets, chi-square %.1f", h, x2)
+		if x2 > limit {
+			t.Errorf("%T: addresses spread unevenly over 64 buckets, chi-square %.1f", h, x2)
+		}
+	}
+
+	//for comparison: the byte sum puts every key into a handful of buckets
+	sum := chiSquare(hasherFunc(func(s string, bits int) *big.Int {
+		return new(big.Int).SetUint64(ModHash(s) % (1 << uint(bits)))
+	}), 6, 64000, keys)
+	t.Logf("ModHash: keys over 64 buckets, chi-square %.1f", sum)
+	if sum <= limit {
+		t.Errorf("ModHash passes the balance test, chi-square %.1f; the test is too weak", sum)
+	}
+}
+
+func TestHashRange(t *testing.T) {
+	for _, h := range []Hasher{SHA1{}, SHA256{}} {
+		for _, bits := range []int{1, 8, 13, 16, 64, 160} {
+			limit := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+			for i := 0; i < 1000; i++ {
+				x := h.Hash("key"+strconv.Itoa(i), bits)
+				if x.Sign() < 0 || x.Cmp(limit) >= 0 {
+					t.Fatalf("%T: Hash(key%d, %d) = %s is not below 2^%d", h, i, bits, x, bits)
+				}
+			}
+		}
+	}
+}
+
+func TestAnagramsDoNotCollide(t *testing.T) {
+	if ModHash("keyAB") != ModHash("keyBA") {
+		t.Fatal("ModHash no longer sums the bytes; drop this check")
+	}
+	for _, h := range []Hasher{SHA1{}, SHA256{}} {
+		for _, bits := range []int{16, 160} {
+			if h.Hash("keyAB", bits).Cmp(h.Hash("keyBA", bits)) == 0 {
+				t.Errorf("%T: keyAB and keyBA collide in %d bits", h, bits)
+			}
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for name, want := range map[string]Hasher{"": SHA1{}, "sha1": SHA1{}, "SHA-1": SHA1{}, "sha256": SHA256{}} {
+		if h, e := New(name); e != nil || h != want {
+			t.Errorf("New(%q) = %T, %v", name, h, e)
+		}
+	}
+	if _, e := New("md5"); e == nil {
+		t.Error("New(\"md5\") does not fail")
+	}
+}
+
+// hasherFunc makes a Hasher of a function
+type hasherFunc func(keyword string, bits int) *big.Int
+
+func (f hasherFunc) Hash(keyword string, bits int) *big.Int {
+	return f(keyword, bits)
+}